@@ -2,6 +2,8 @@
 package client
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
 	"fmt"
 	"io"
@@ -11,6 +13,8 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/taybart/log"
+	"github.com/taybart/rest/history"
 	"github.com/taybart/rest/request"
 )
 
@@ -18,20 +22,45 @@ type Client struct {
 	client *http.Client
 	ws     *websocket.Conn
 	Config request.Config
+	// when set every request sent with Do is recorded
+	History *history.Store
+	// set when cookie_file is configured, saved after every request
+	jar *Jar
 }
 
 func New(config request.Config) (*Client, error) {
 	client := http.Client{}
+	var persistent *Jar
 	if !config.NoCookies {
-		jar, err := cookiejar.New(nil)
-		if err != nil {
-			return nil, err
+		if config.CookieFile != "" {
+			jar, err := OpenJar(config.CookieFile)
+			if err != nil {
+				return nil, err
+			}
+			persistent = jar
+			client.Jar = jar
+		} else {
+			jar, err := cookiejar.New(nil)
+			if err != nil {
+				return nil, err
+			}
+			client.Jar = jar
+		}
+	}
+	if config.NoFollowRedirect {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	if config.InsecureNoVerifyTLS {
+		client.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
 		}
-		client.Jar = jar
 	}
 	return &Client{
 		client: &client,
 		Config: config,
+		jar:    persistent,
 	}, nil
 }
 
@@ -44,29 +73,36 @@ func (c *Client) Do(r request.Request) (string, map[string]any, error) {
 		}
 		time.Sleep(delay)
 	}
-	r.UserAgent = c.Config.UserAgent
-
-	req, err := r.Build()
+	start := time.Now()
+	res, err := c.Send(r)
 	if err != nil {
+		c.record(r, nil, nil, time.Since(start), nil, err)
 		return "", nil, err
 	}
-
-	if c.Config.NoFollowRedirect {
-		c.client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
+	elapsed := time.Since(start)
+	if c.jar != nil {
+		if err := c.jar.Save(); err != nil {
+			log.Warn("could not save cookies:", err)
 		}
 	}
-	if c.Config.InsecureNoVerifyTLS {
-		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+
+	var body []byte
+	if c.History != nil {
+		// read the body up front so hooks and expectations still get it
+		body, err = io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return "", nil, err
 		}
-		c.client.Transport = tr
+		res.Body = io.NopCloser(bytes.NewReader(body))
 	}
 
-	res, err := c.client.Do(req)
-	if err != nil {
-		return "", nil, err
-	}
+	dumped, exports, err := c.handle(r, res)
+	c.record(r, res, body, elapsed, exports, err)
+	return dumped, exports, err
+}
+
+func (c *Client) handle(r request.Request, res *http.Response) (string, map[string]any, error) {
 	// run lua code if it exists
 	if r.After != "" {
 		exports, err := r.RunAfterHook(res, c.client.Jar)
@@ -80,6 +116,55 @@ func (c *Client) Do(r request.Request) (string, map[string]any, error) {
 
 	return dumped, nil, err
 }
+
+func (c *Client) record(r request.Request, res *http.Response, body []byte, elapsed time.Duration, exports map[string]any, err error) {
+	if c.History == nil {
+		return
+	}
+	entry := history.Entry{
+		Time:     time.Now(),
+		Label:    r.Label,
+		Duration: elapsed,
+		Request: history.Request{
+			Method: r.Method,
+			URL:    r.URL,
+			Body:   r.Body,
+		},
+		Exports: exports,
+	}
+	if res != nil {
+		entry.Request.URL = res.Request.URL.String()
+		entry.Request.Headers = res.Request.Header
+		entry.Response = &history.Response{
+			Status:  res.StatusCode,
+			Headers: res.Header,
+			Body:    string(body),
+		}
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if err := c.History.Append(entry); err != nil {
+		log.Warn("could not write history:", err)
+	}
+}
+
+// Send builds and sends the request without running delays, hooks or
+// expectations, the caller is responsible for closing the response body
+func (c *Client) Send(r request.Request) (*http.Response, error) {
+	return c.send(context.Background(), c.client, r)
+}
+
+func (c *Client) send(ctx context.Context, hc *http.Client, r request.Request) (*http.Response, error) {
+	r.UserAgent = c.Config.UserAgent
+
+	req, err := r.Build()
+	if err != nil {
+		return nil, err
+	}
+	return hc.Do(req.WithContext(ctx))
+}
+
 func (c *Client) CheckExpectation(r request.Request, res *http.Response) (string, error) {
 	dumped, err := httputil.DumpResponse(res, true)
 	if err != nil {
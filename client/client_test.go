@@ -1,15 +1,21 @@
 package client_test
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/taybart/rest"
 	"github.com/taybart/rest/client"
+	"github.com/taybart/rest/request"
 )
 
 func parse(t *testing.T, filename string, expectedReqs int) *rest.Rest {
@@ -105,3 +111,130 @@ func TestAuthdRequest(t *testing.T) {
 		t.Fatal("expected auth to be token got:", req.Header.Get("Authorization"))
 	}
 }
+
+func TestLoad(t *testing.T) {
+	var count atomic.Int64
+	serve := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// every other request fails the expectation
+		if count.Add(1)%2 == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, "hello world")
+	}))
+	defer serve.Close()
+
+	c, err := client.New(request.DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Load(context.Background(), request.Request{Method: "GET", URL: serve.URL}, client.LoadConfig{
+		RPS:      2 * int(time.Second),
+		Duration: time.Second,
+	}); err == nil {
+		t.Fatal("expected rps faster than the ticker to be rejected")
+	}
+	report, err := c.Load(context.Background(), request.Request{
+		Label:        "load",
+		Method:       "GET",
+		URL:          serve.URL,
+		ExpectStatus: http.StatusOK,
+	}, client.LoadConfig{
+		RPS:         200,
+		Duration:    250 * time.Millisecond,
+		Concurrency: 4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := len(report.Samples)
+	if total == 0 || int64(total) != count.Load() {
+		t.Fatalf("expected samples for every request, got %d of %d", total, count.Load())
+	}
+	if report.Errors() != total/2 {
+		t.Fatalf("expected %d failed expectations, got %d", total/2, report.Errors())
+	}
+	if report.Percentile(99) < report.Percentile(50) {
+		t.Fatal("expected p99 to be >= p50")
+	}
+	if len(report.TimeSeries()) == 0 {
+		t.Fatal("expected time series windows")
+	}
+}
+
+func TestCookieFile(t *testing.T) {
+	serve := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc123", Path: "/", HttpOnly: true})
+			return
+		}
+		if _, err := r.Cookie("session"); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer serve.Close()
+
+	config := request.DefaultConfig()
+	config.CookieFile = filepath.Join(t.TempDir(), "cookies.txt")
+	do := func(path string, status int) {
+		// new client every time like separate invocations
+		c, err := client.New(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, err = c.Do(request.Request{
+			Label: path, Method: "GET", URL: serve.URL + path, ExpectStatus: status,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	do("/login", http.StatusOK)
+	do("/me", http.StatusOK)
+
+	jar, err := client.OpenJar(config.CookieFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := jar.List()
+	if len(cookies) != 1 || cookies[0].Value != "abc123" || !cookies[0].HttpOnly {
+		t.Fatalf("expected session cookie to be saved, got %+v", cookies)
+	}
+	if err := jar.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	do("/me", http.StatusUnauthorized)
+}
+
+func TestJarRejectsOtherDomains(t *testing.T) {
+	jar, err := client.OpenJar(filepath.Join(t.TempDir(), "cookies.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse("http://www.evil.test/")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "injected", Value: "1", Domain: "bank.test"},
+		{Name: "parent", Value: "1", Domain: ".evil.test"},
+	})
+	cookies := jar.List()
+	if len(cookies) != 1 || cookies[0].Name != "parent" || cookies[0].Domain != "evil.test" {
+		t.Fatalf("expected only the evil.test cookie to be kept, got %+v", cookies)
+	}
+}
+
+func TestJarImportCurlJar(t *testing.T) {
+	dir := t.TempDir()
+	// curl -c writes cookies.txt without an extension unless told otherwise
+	curlJar := filepath.Join(dir, "cookies")
+	content := "# Netscape HTTP Cookie File\nexample.com\tFALSE\t/\tFALSE\t0\tsid\tabc\n"
+	if err := os.WriteFile(curlJar, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	jar, err := client.OpenJar(filepath.Join(dir, "jar.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := jar.Import(curlJar); err != nil || n != 1 {
+		t.Fatal("expected one cookie from the curl jar", n, err)
+	}
+}
@@ -0,0 +1,321 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cookie is a cookie as stored in a cookie file
+type Cookie struct {
+	Domain string `json:"domain"`
+	Path   string `json:"path"`
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	// zero for session cookies
+	Expires  time.Time `json:"expires,omitzero"`
+	Secure   bool      `json:"secure,omitempty"`
+	HttpOnly bool      `json:"http_only,omitempty"`
+	// cookie is only sent to Domain and not its subdomains
+	HostOnly bool `json:"host_only,omitempty"`
+}
+
+func (c Cookie) key() string {
+	return c.Domain + ";" + c.Path + ";" + c.Name
+}
+
+func (c Cookie) expired() bool {
+	return !c.Expires.IsZero() && c.Expires.Before(time.Now())
+}
+
+// Jar is a cookie jar that is loaded from and saved to a file, files ending
+// in .txt use the netscape cookies.txt format (curl, browser extensions)
+// anything else is json
+type Jar struct {
+	File    string
+	mu      sync.Mutex
+	jar     *cookiejar.Jar
+	cookies map[string]Cookie
+}
+
+// OpenJar loads the jar from filename, a missing file is an empty jar
+func OpenJar(filename string) (*Jar, error) {
+	j := &Jar{File: filename}
+	if err := j.reset(); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return j, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	cookies, err := readCookies(f, isNetscape(filename))
+	if err != nil {
+		return nil, fmt.Errorf("reading cookie file %s: %w", filename, err)
+	}
+	j.Add(cookies...)
+	return j, nil
+}
+
+func (j *Jar) reset() error {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return err
+	}
+	j.jar = jar
+	j.cookies = map[string]Cookie{}
+	return nil
+}
+
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.jar.Cookies(u)
+}
+
+// SetCookies stores cookies in the underlying jar and keeps track of them so
+// they can be saved, cookies the jar rejects aren't kept
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.jar.SetCookies(u, cookies)
+	host := strings.ToLower(u.Hostname())
+	for _, hc := range cookies {
+		c := Cookie{
+			Domain:   strings.TrimPrefix(strings.ToLower(hc.Domain), "."),
+			Path:     hc.Path,
+			Name:     hc.Name,
+			Value:    hc.Value,
+			Secure:   hc.Secure,
+			HttpOnly: hc.HttpOnly,
+		}
+		if c.Domain == "" {
+			c.Domain = host
+			c.HostOnly = true
+		} else if !domainMatch(host, c.Domain) {
+			// the jar drops these too, keeping them would let any server set
+			// cookies for other sites the next time the file is loaded
+			continue
+		} else if net.ParseIP(host) != nil {
+			// ips have no subdomains so the jar treats these as host only
+			c.HostOnly = true
+		}
+		if c.Path == "" || !strings.HasPrefix(c.Path, "/") {
+			c.Path = defaultPath(u.Path)
+		}
+		switch {
+		case hc.MaxAge < 0:
+			delete(j.cookies, c.key())
+			continue
+		case hc.MaxAge > 0:
+			c.Expires = time.Now().Add(time.Duration(hc.MaxAge) * time.Second)
+		case !hc.Expires.IsZero():
+			c.Expires = hc.Expires
+		}
+		if c.expired() {
+			delete(j.cookies, c.key())
+			continue
+		}
+		j.cookies[c.key()] = c
+	}
+}
+
+// domainMatch reports whether a cookie for domain can be set by host, RFC
+// 6265 section 5.1.3
+func domainMatch(host, domain string) bool {
+	if host == domain {
+		return true
+	}
+	return net.ParseIP(host) == nil && strings.HasSuffix(host, "."+domain)
+}
+
+// defaultPath is the directory of the request path, RFC 6265 section 5.1.4
+func defaultPath(p string) string {
+	if p == "" || p[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndex(p, "/")
+	if i == 0 {
+		return "/"
+	}
+	return p[:i]
+}
+
+// Add puts cookies into the jar as if a server had set them
+func (j *Jar) Add(cookies ...Cookie) {
+	for _, c := range cookies {
+		if c.expired() {
+			continue
+		}
+		u := &url.URL{Scheme: "http", Host: c.Domain, Path: c.Path}
+		if c.Secure {
+			u.Scheme = "https"
+		}
+		hc := &http.Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Expires:  c.Expires,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+		}
+		if !c.HostOnly {
+			hc.Domain = c.Domain
+		}
+		j.SetCookies(u, []*http.Cookie{hc})
+	}
+}
+
+// List returns the unexpired cookies sorted by domain, path and name
+func (j *Jar) List() []Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	cookies := []Cookie{}
+	for _, k := range slices.Sorted(maps.Keys(j.cookies)) {
+		if c := j.cookies[k]; !c.expired() {
+			cookies = append(cookies, c)
+		}
+	}
+	return cookies
+}
+
+func (j *Jar) Save() error {
+	return j.Export(j.File)
+}
+
+// Export writes the jar to filename in the format given by its extension
+func (j *Jar) Export(filename string) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		return err
+	}
+	// cookies are usually credentials
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return writeCookies(f, j.List(), isNetscape(filename))
+}
+
+// Import adds the cookies from filename to the jar, files that aren't json
+// are read as cookies.txt whatever they are called since curl's jars often
+// don't have an extension
+func (j *Jar) Import(filename string) (int, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	netscape := isNetscape(filename) || !bytes.HasPrefix(bytes.TrimSpace(b), []byte("["))
+	cookies, err := readCookies(bytes.NewReader(b), netscape)
+	if err != nil {
+		return 0, fmt.Errorf("reading cookie file %s: %w", filename, err)
+	}
+	j.Add(cookies...)
+	return len(cookies), nil
+}
+
+// Clear empties the jar and removes the file
+func (j *Jar) Clear() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.reset(); err != nil {
+		return err
+	}
+	if err := os.Remove(j.File); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func isNetscape(filename string) bool {
+	return path.Ext(filename) == ".txt"
+}
+
+func readCookies(r io.Reader, netscape bool) ([]Cookie, error) {
+	if !netscape {
+		cookies := []Cookie{}
+		if err := json.NewDecoder(r).Decode(&cookies); err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		return cookies, nil
+	}
+	const httpOnlyPrefix = "#HttpOnly_"
+	cookies := []Cookie{}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		httpOnly := strings.HasPrefix(line, httpOnlyPrefix)
+		line = strings.TrimPrefix(line, httpOnlyPrefix)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("line %d: expected 7 tab separated fields got %d", n, len(fields))
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad expiry %q", n, fields[4])
+		}
+		c := Cookie{
+			Domain:   strings.TrimPrefix(fields[0], "."),
+			HostOnly: !strings.EqualFold(fields[1], "TRUE"),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}
+		if expires > 0 {
+			c.Expires = time.Unix(expires, 0)
+		}
+		cookies = append(cookies, c)
+	}
+	return cookies, scanner.Err()
+}
+
+func writeCookies(w io.Writer, cookies []Cookie, netscape bool) error {
+	if !netscape {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(cookies)
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# Netscape HTTP Cookie File")
+	upper := func(b bool) string { return strings.ToUpper(strconv.FormatBool(b)) }
+	for _, c := range cookies {
+		domain := c.Domain
+		if !c.HostOnly {
+			domain = "." + domain
+		}
+		if c.HttpOnly {
+			domain = "#HttpOnly_" + domain
+		}
+		var expires int64
+		if !c.Expires.IsZero() {
+			expires = c.Expires.Unix()
+		}
+		fmt.Fprintf(bw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, upper(!c.HostOnly), c.Path, upper(c.Secure), expires, c.Name, c.Value)
+	}
+	return bw.Flush()
+}
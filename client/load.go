@@ -0,0 +1,316 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/taybart/rest/request"
+)
+
+type LoadConfig struct {
+	// requests per second across all workers, 0 sends as fast as the workers can
+	RPS         int
+	Duration    time.Duration
+	Concurrency int
+}
+
+type LoadSample struct {
+	Start   time.Time
+	Latency time.Duration
+	Status  int
+	// transport error or failed expectation
+	Err error
+}
+
+type LoadReport struct {
+	Label    string
+	Config   LoadConfig
+	Elapsed  time.Duration
+	Samples  []LoadSample
+	started  time.Time
+	sorted   []time.Duration
+	statuses map[int]int
+	errors   map[string]int
+}
+
+// Load sends r repeatedly for the configured duration and collects latency,
+// status and error samples, expectations are checked for every response
+func (c *Client) Load(ctx context.Context, r request.Request, config LoadConfig) (*LoadReport, error) {
+	if config.Concurrency <= 0 {
+		config.Concurrency = 1
+	}
+	if config.Duration <= 0 {
+		return nil, fmt.Errorf("load duration must be positive")
+	}
+	// the ticker can't tick faster than once a nanosecond
+	if config.RPS > int(time.Second) {
+		return nil, fmt.Errorf("load rps can't be more than %d", int(time.Second))
+	}
+	// every send needs a fresh body so never reuse a built request
+	r.Built = nil
+	probe := r
+	if _, err := probe.Build(); err != nil {
+		return nil, err
+	}
+
+	// keep connections around for every worker instead of the default 2, on a
+	// copy so the client's own transport is left alone
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.MaxIdleConnsPerHost = config.Concurrency
+	if c.Config.InsecureNoVerifyTLS {
+		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	defer tr.CloseIdleConnections()
+	hc := *c.client
+	hc.Transport = tr
+
+	ctx, cancel := context.WithTimeout(ctx, config.Duration)
+	defer cancel()
+
+	jobs := make(chan struct{}, config.Concurrency)
+	go func() {
+		defer close(jobs)
+		if config.RPS <= 0 {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case jobs <- struct{}{}:
+				}
+			}
+		}
+		ticker := time.NewTicker(time.Second / time.Duration(config.RPS))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				select {
+				case <-ctx.Done():
+					return
+				case jobs <- struct{}{}:
+				}
+			}
+		}
+	}()
+
+	report := &LoadReport{Label: r.Label, Config: config, started: time.Now()}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for range config.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				sample := c.loadOnce(ctx, &hc, r)
+				// requests cut off by the end of the run aren't errors
+				if errors.Is(sample.Err, context.DeadlineExceeded) || errors.Is(sample.Err, context.Canceled) {
+					continue
+				}
+				mu.Lock()
+				report.Samples = append(report.Samples, sample)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	report.Elapsed = time.Since(report.started)
+	report.summarize()
+	return report, nil
+}
+
+func (c *Client) loadOnce(ctx context.Context, hc *http.Client, r request.Request) LoadSample {
+	sample := LoadSample{Start: time.Now()}
+	res, err := c.send(ctx, hc, r)
+	if err != nil {
+		sample.Latency = time.Since(sample.Start)
+		sample.Err = err
+		return sample
+	}
+	defer res.Body.Close()
+	if r.Expect != nil || r.ExpectStatus != 0 {
+		_, sample.Err = c.CheckExpectation(r, res)
+	}
+	io.Copy(io.Discard, res.Body)
+	sample.Latency = time.Since(sample.Start)
+	sample.Status = res.StatusCode
+	return sample
+}
+
+func (r *LoadReport) summarize() {
+	r.sorted = make([]time.Duration, len(r.Samples))
+	r.statuses = map[int]int{}
+	r.errors = map[string]int{}
+	for i, s := range r.Samples {
+		r.sorted[i] = s.Latency
+		if s.Status != 0 {
+			r.statuses[s.Status]++
+		}
+		if s.Err != nil {
+			r.errors[s.Err.Error()]++
+		}
+	}
+	slices.Sort(r.sorted)
+}
+
+// Percentile returns the latency at p (0-100)
+func (r *LoadReport) Percentile(p float64) time.Duration {
+	return percentile(r.sorted, p)
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(float64(len(sorted)-1) * p / 100)
+	return sorted[idx]
+}
+
+func (r *LoadReport) Errors() int {
+	total := 0
+	for _, n := range r.errors {
+		total += n
+	}
+	return total
+}
+
+func (r *LoadReport) Throughput() float64 {
+	if r.Elapsed == 0 {
+		return 0
+	}
+	return float64(len(r.Samples)) / r.Elapsed.Seconds()
+}
+
+func (r *LoadReport) Print(w io.Writer) {
+	total := len(r.Samples)
+	fmt.Fprintf(w, "load test %q: %d requests in %s (%d workers", r.Label, total,
+		r.Elapsed.Round(time.Millisecond), r.Config.Concurrency)
+	if r.Config.RPS > 0 {
+		fmt.Fprintf(w, ", %d rps target", r.Config.RPS)
+	}
+	fmt.Fprintln(w, ")")
+	if total == 0 {
+		return
+	}
+	fmt.Fprintf(w, "  throughput: %.2f req/s\n", r.Throughput())
+	fmt.Fprintf(w, "  error rate: %.2f%% (%d)\n", float64(r.Errors())/float64(total)*100, r.Errors())
+
+	fmt.Fprintln(w, "\nlatency:")
+	for _, p := range []float64{50, 90, 99} {
+		fmt.Fprintf(w, "  p%-3.0f %s\n", p, r.Percentile(p).Round(time.Microsecond))
+	}
+	fmt.Fprintf(w, "  max  %s\n", r.sorted[len(r.sorted)-1].Round(time.Microsecond))
+
+	fmt.Fprintln(w, "\nhistogram:")
+	r.printHistogram(w)
+
+	fmt.Fprintln(w, "\nstatus codes:")
+	for _, code := range slices.Sorted(maps.Keys(r.statuses)) {
+		fmt.Fprintf(w, "  [%d] %d\n", code, r.statuses[code])
+	}
+	if len(r.errors) > 0 {
+		fmt.Fprintln(w, "\nerrors:")
+		for _, msg := range slices.Sorted(maps.Keys(r.errors)) {
+			fmt.Fprintf(w, "  [%d] %s\n", r.errors[msg], msg)
+		}
+	}
+}
+
+func (r *LoadReport) printHistogram(w io.Writer) {
+	const buckets = 10
+	const width = 40
+	lo, hi := r.sorted[0], r.sorted[len(r.sorted)-1]
+	step := (hi - lo) / buckets
+	if step == 0 {
+		step = 1
+	}
+	counts := make([]int, buckets+1)
+	for _, d := range r.sorted {
+		counts[min(int((d-lo)/step), buckets)]++
+	}
+	most := slices.Max(counts)
+	for i, n := range counts {
+		bar := strings.Repeat("■", n*width/most)
+		fmt.Fprintf(w, "  %10s [%d]\t|%s\n", (lo + step*time.Duration(i)).Round(time.Microsecond), n, bar)
+	}
+}
+
+type LoadWindow struct {
+	Second   int     `json:"second"`
+	Requests int     `json:"requests"`
+	Errors   int     `json:"errors"`
+	P50      float64 `json:"p50_ms"`
+	P90      float64 `json:"p90_ms"`
+	P99      float64 `json:"p99_ms"`
+	Max      float64 `json:"max_ms"`
+}
+
+// TimeSeries buckets samples into one second windows
+func (r *LoadReport) TimeSeries() []LoadWindow {
+	seconds := int(r.Elapsed/time.Second) + 1
+	latencies := make([][]time.Duration, seconds)
+	windows := make([]LoadWindow, seconds)
+	for _, s := range r.Samples {
+		i := min(int(s.Start.Sub(r.started)/time.Second), seconds-1)
+		latencies[i] = append(latencies[i], s.Latency)
+		windows[i].Requests++
+		if s.Err != nil {
+			windows[i].Errors++
+		}
+	}
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	for i := range windows {
+		windows[i].Second = i
+		slices.Sort(latencies[i])
+		windows[i].P50 = ms(percentile(latencies[i], 50))
+		windows[i].P90 = ms(percentile(latencies[i], 90))
+		windows[i].P99 = ms(percentile(latencies[i], 99))
+		if n := len(latencies[i]); n > 0 {
+			windows[i].Max = ms(latencies[i][n-1])
+		}
+	}
+	return windows
+}
+
+// WriteTimeSeries writes the per second windows as json if the filename ends
+// in .json otherwise as csv
+func (r *LoadReport) WriteTimeSeries(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	windows := r.TimeSeries()
+	if filepath.Ext(filename) == ".json" {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		return enc.Encode(windows)
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"second", "requests", "errors", "p50_ms", "p90_ms", "p99_ms", "max_ms"})
+	f64 := func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+	for _, win := range windows {
+		w.Write([]string{
+			strconv.Itoa(win.Second), strconv.Itoa(win.Requests), strconv.Itoa(win.Errors),
+			f64(win.P50), f64(win.P90), f64(win.P99), f64(win.Max),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
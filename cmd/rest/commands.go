@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/taybart/rest"
+	"github.com/taybart/rest/imports"
+	"github.com/taybart/rest/server"
+)
+
+// commands are positional subcommands that still use the regular flags
+// ex. rest history -f api.rest -l label
+var commands = map[string]func(args []string) error{
+	"history": historyCmd,
+	"diff":    diffCmd,
+	"cookies": cookiesCmd,
+	"import":  importCmd,
+	// rest record-to-rest recordings/ > mock.rest
+	"record-to-rest": recordToRestCmd,
+	// rest cert gen --hosts api.local,127.0.0.1 -o keys
+	"cert": certCmd,
+}
+
+// subcommand returns the command named by the first argument along with the
+// positional arguments that follow it
+func subcommand() (func([]string) error, []string) {
+	if len(os.Args) < 2 {
+		return nil, nil
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		return nil, nil
+	}
+	positional := []string{}
+	args := os.Args[2:]
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "-" || !strings.HasPrefix(arg, "-") {
+			positional = append(positional, arg)
+			continue
+		}
+		name := strings.TrimLeft(arg, "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+		// skip the value of flags that take one
+		for long, def := range a.Args {
+			if (long == name || def.Short == name) && !def.IsBoolFlag() {
+				i++
+				break
+			}
+		}
+	}
+	return cmd, positional
+}
+
+func historyCmd(_ []string) error {
+	if !a.UserSet("file") {
+		return errors.New("history requires -f")
+	}
+	f, err := rest.NewFile(c.File)
+	if err != nil {
+		return err
+	}
+	return f.History(c.Label)
+}
+
+func diffCmd(_ []string) error {
+	if !a.UserSet("file") {
+		return errors.New("diff requires -f")
+	}
+	f, err := rest.NewFile(c.File)
+	if err != nil {
+		return err
+	}
+	return f.Diff(c.Label, c.Against)
+}
+
+func cookiesCmd(args []string) error {
+	if !a.UserSet("file") {
+		return errors.New("cookies requires -f")
+	}
+	f, err := rest.NewFile(c.File)
+	if err != nil {
+		return err
+	}
+	action := "ls"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	if action == "ls" {
+		return f.Cookies()
+	}
+	jar, err := f.CookieJar()
+	if err != nil {
+		return err
+	}
+	switch action {
+	case "clear":
+		return jar.Clear()
+	case "import", "export":
+		if len(args) < 2 {
+			return fmt.Errorf("cookies %s requires a file", action)
+		}
+		if action == "export" {
+			return jar.Export(args[1])
+		}
+		n, err := jar.Import(args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("imported %d cookie(s) into %s\n", n, jar.File)
+		return jar.Save()
+	}
+	return fmt.Errorf("unknown cookies command %q (ls, clear, import, export)", action)
+}
+
+// importers convert sources into a rest file written to stdout
+var importers = map[string]func(sources []string) (*imports.File, error){
+	"openapi": func(sources []string) (*imports.File, error) {
+		return imports.OpenAPI(sources[0])
+	},
+	// rest import postman collection.json [environment.json...]
+	"postman": func(sources []string) (*imports.File, error) {
+		return imports.Postman(sources[0], sources[1:]...)
+	},
+	// rest import curl '<command>', the command is read from stdin when missing
+	"curl": func(_ []string) (*imports.File, error) {
+		switch {
+		case len(curlArgs) == 0 || (len(curlArgs) == 1 && curlArgs[0] == "-"):
+			command, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return nil, err
+			}
+			return imports.Curl(string(command))
+		case len(curlArgs) == 1:
+			return imports.Curl(curlArgs[0])
+		}
+		// unquoted command, quote each word so it splits the same way again
+		quoted := make([]string, len(curlArgs))
+		for i, arg := range curlArgs {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+		return imports.Curl(strings.Join(quoted, " "))
+	},
+	// rest import http requests.http
+	"http": func(sources []string) (*imports.File, error) {
+		return imports.HTTP(sources[0])
+	},
+	// rest import har file.har [host...]
+	"har": func(sources []string) (*imports.File, error) {
+		return imports.HAR(sources[0], sources[1:]...)
+	},
+}
+
+// curlArgs holds everything after "rest import curl", it is taken out of
+// os.Args before flags are parsed since curl flags collide with ours
+var curlArgs []string
+
+func takeCurlArgs() {
+	if len(os.Args) > 3 && os.Args[1] == "import" && os.Args[2] == "curl" {
+		curlArgs = os.Args[3:]
+		os.Args = os.Args[:3]
+	}
+}
+
+func importCmd(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: rest import <%s> <source>", strings.Join(slices.Sorted(maps.Keys(importers)), "|"))
+	}
+	importer, ok := importers[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown import format %q", args[0])
+	}
+	if len(args) < 2 && args[0] != "curl" {
+		return fmt.Errorf("usage: rest import %s <source>", args[0])
+	}
+	f, err := importer(args[1:])
+	if err != nil {
+		return err
+	}
+	return f.Write(os.Stdout)
+}
+
+func recordToRestCmd(args []string) error {
+	if len(args) < 1 {
+		return errors.New("usage: rest record-to-rest <proxy_record dir>")
+	}
+	f, err := imports.Recordings(args[0])
+	if err != nil {
+		return err
+	}
+	return f.Write(os.Stdout)
+}
+
+// certCmd writes a ca (or reuses the one in --out) and a certificate for
+// --hosts signed by it, named after the first host
+func certCmd(args []string) error {
+	if len(args) < 1 || args[0] != "gen" {
+		return errors.New("usage: rest cert gen --hosts a,b -o dir")
+	}
+	hosts := []string{}
+	for h := range strings.SplitSeq(c.Hosts, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	if len(hosts) == 0 {
+		return errors.New("cert gen requires --hosts")
+	}
+	caName := filepath.Join(c.Out, "ca")
+	ca, created, err := server.LoadOrNewCA(caName)
+	if err != nil {
+		return err
+	}
+	if created {
+		fmt.Printf("wrote ca to %s.{crt,key}\n", caName)
+	}
+	name := filepath.Join(c.Out, strings.ReplaceAll(hosts[0], "*", "wildcard"))
+	if err := ca.WriteCert(name, hosts); err != nil {
+		return err
+	}
+	fmt.Printf("wrote certificate for %s to %s.{crt,key}\n", strings.Join(hosts, ", "), name)
+	fmt.Printf("serve it with: rest -s -t %s\n", name)
+	return nil
+}
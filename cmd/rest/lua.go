@@ -45,7 +45,7 @@ func do(f *rest.Rest, req request.Request) (map[string]any, error) {
 	}
 
 	// make sure to add the exports back into parsers ctx
-	f.Parser.AddExportsCtx(exports)
+	f.AddExports(req.Label, exports)
 	return exports, nil
 }
 
@@ -153,7 +153,7 @@ func runCLITool(f *rest.Rest, cliBlock file.CLI, cliFlags map[string]string) err
 	}
 
 	var err error
-	rclient, err = client.New(f.Parser.Config)
+	rclient, err = f.NewClient()
 	if err != nil {
 		return err
 	}
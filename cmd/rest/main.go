@@ -1,31 +1,41 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"time"
 
 	"github.com/taybart/args"
 	"github.com/taybart/log"
 	"github.com/taybart/rest"
+	"github.com/taybart/rest/client"
 	"github.com/taybart/rest/file"
 	"github.com/taybart/rest/server"
 )
 
 func usage(u args.Usage) {
 	cli := []string{
-		"no-color", "list",
+		"no-color", "list", "watch",
 	}
 	server := []string{
 		"addr", "serve", "dir", "spa", "file",
-		"cors", "response", "tls", "quiet",
+		"cors", "response", "tls", "quiet", "openapi",
 	}
 	client := []string{
 		"file", "block", "label",
 		"socket", "export", "verbose",
 	}
+	history := []string{
+		"file", "label", "against",
+	}
+	load := []string{
+		"load", "rps", "duration", "concurrency", "load-out",
+	}
 
 	var usage strings.Builder
 	fmt.Fprintf(&usage, "%s\t\t=== Rest Easy ===\n%s", log.BoldBlue, log.Reset)
@@ -35,6 +45,16 @@ func usage(u args.Usage) {
 	u.BuildFlagString(&usage, server)
 	fmt.Fprintf(&usage, "%sClient:\n%s", log.BoldGreen, log.Reset)
 	u.BuildFlagString(&usage, client)
+	fmt.Fprintf(&usage, "%sHistory (rest history|diff):\n%s", log.BoldGreen, log.Reset)
+	u.BuildFlagString(&usage, history)
+	fmt.Fprintf(&usage, "%sCookies (rest cookies ls|clear|import <file>|export <file>):\n%s", log.BoldGreen, log.Reset)
+	u.BuildFlagString(&usage, []string{"file"})
+	fmt.Fprintf(&usage, "%sImport (rest import openapi|postman|curl|har|http <source> > api.rest)\n%s", log.BoldGreen, log.Reset)
+	fmt.Fprintf(&usage, "%sRecordings (rest record-to-rest <proxy_record dir> > mock.rest)\n%s", log.BoldGreen, log.Reset)
+	fmt.Fprintf(&usage, "%sCerts (rest cert gen):\n%s", log.BoldGreen, log.Reset)
+	u.BuildFlagString(&usage, []string{"hosts", "out"})
+	fmt.Fprintf(&usage, "%sLoad:\n%s", log.BoldGreen, log.Reset)
+	u.BuildFlagString(&usage, load)
 	fmt.Println(usage.String())
 }
 
@@ -65,6 +85,11 @@ var (
 				Help:    "List labels in file",
 				Default: false,
 			},
+			"watch": {
+				Short:   "w",
+				Help:    "Re-run (or reload the server) when the file, its imports or read() files change",
+				Default: false,
+			},
 
 			/*** server ***/
 			"addr": {
@@ -92,7 +117,11 @@ var (
 			},
 			"tls": {
 				Short:   "t",
-				Help:    "TLS path name to be used for tls key/cert (defaults to no TLS)\n\tex: '-t ./keys/site.com' where the files ./keys/site.com.{key,crt} exist",
+				Help:    "TLS path name to be used for tls key/cert (defaults to no TLS)\n\tex: '-t ./keys/site.com' where the files ./keys/site.com.{key,crt} exist\n\tor 'auto' to generate a certificate at startup",
+				Default: "",
+			},
+			"openapi": {
+				Help:    "OpenAPI spec (json or yaml) to mock, requests are validated against it",
 				Default: "",
 			},
 			// TODO: add to server block
@@ -123,6 +152,41 @@ var (
 				Help:    "Ignore errors and run all blocks",
 				Default: false,
 			},
+			"against": {
+				Help:    "Run to compare the latest run with in \"rest diff\", as numbered by \"rest history\"",
+				Default: 1,
+			},
+			/*** certs ***/
+			"hosts": {
+				Help:    "Comma separated hosts (names or ips) for \"rest cert gen\"",
+				Default: "localhost,127.0.0.1,::1",
+			},
+			"out": {
+				Short:   "o",
+				Help:    "Directory \"rest cert gen\" writes to, an existing ca.{crt,key} there is reused",
+				Default: ".",
+			},
+			/*** load ***/
+			"load": {
+				Help:    "Load test the request selected with -l/-b",
+				Default: false,
+			},
+			"rps": {
+				Help:    "Requests per second for --load, 0 is as fast as possible",
+				Default: 0,
+			},
+			"duration": {
+				Help:    "How long to run --load for",
+				Default: "10s",
+			},
+			"concurrency": {
+				Help:    "Number of workers for --load",
+				Default: 10,
+			},
+			"load-out": {
+				Help:    "Write per second --load stats to a .csv or .json file",
+				Default: "",
+			},
 			/*** socket ***/
 			"socket": {
 				Short:            "S",
@@ -139,6 +203,7 @@ var (
 		NoColor bool `arg:"no-color"`
 		Quiet   bool `arg:"quiet"`
 		Verbose bool `arg:"verbose"`
+		Watch   bool `arg:"watch"`
 
 		// server
 		Addr     string `arg:"addr"`
@@ -148,6 +213,7 @@ var (
 		Cors     bool   `arg:"cors"`
 		TLS      string `arg:"tls"`
 		SPA      bool   `arg:"spa"`
+		OpenAPI  string `arg:"openapi"`
 
 		// client
 		File       string `arg:"file"`
@@ -157,6 +223,18 @@ var (
 		Socket     string `arg:"socket"`
 		Export     string `arg:"export"`
 		IgnoreFail bool   `arg:"ignore-fail"`
+		Against    int    `arg:"against"`
+
+		// load
+		Load        bool   `arg:"load"`
+		RPS         int    `arg:"rps"`
+		Duration    string `arg:"duration"`
+		Concurrency int    `arg:"concurrency"`
+		LoadOut     string `arg:"load-out"`
+
+		// certs
+		Hosts string `arg:"hosts"`
+		Out   string `arg:"out"`
 	}{}
 )
 
@@ -209,6 +287,8 @@ func run() error {
 		}
 	}
 
+	takeCurlArgs()
+
 	if err := a.Parse(); err != nil {
 		if errors.Is(err, args.ErrUsageRequested) {
 			return nil
@@ -226,11 +306,20 @@ func run() error {
 		log.SetLevel(log.TRACE)
 	}
 
+	if cmd, args := subcommand(); cmd != nil {
+		return cmd(args)
+	}
+
 	/**********
 	 * SERVER *
 	 **********/
 	if c.Serve {
 		if a.UserSet("file") {
+			if c.Watch {
+				ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+				defer stop()
+				return rest.WatchServer(ctx, c.File)
+			}
 			f, err := rest.NewFile(c.File)
 			if err != nil {
 				return err
@@ -249,6 +338,8 @@ func run() error {
 			Cors:     c.Cors,
 			Response: res,
 			SPA:      c.SPA,
+			TLS:      c.TLS,
+			OpenAPI:  c.OpenAPI,
 		})
 		return s.Serve()
 	}
@@ -275,6 +366,22 @@ func run() error {
 		return f.RunSocket(c.Socket)
 	}
 
+	if c.Load {
+		if c.Label == "" && c.Block < 0 {
+			return errors.New("--load requires a request selected with -l or -b")
+		}
+		duration, err := time.ParseDuration(c.Duration)
+		if err != nil {
+			return fmt.Errorf("invalid --duration: %w", err)
+		}
+		log.Debug("load testing", c.Label, "on file", c.File)
+		return f.RunLoad(c.Label, c.Block, client.LoadConfig{
+			RPS:         c.RPS,
+			Duration:    duration,
+			Concurrency: c.Concurrency,
+		}, c.LoadOut)
+	}
+
 	if c.List {
 		for _, b := range f.Requests {
 			fmt.Println(b.Label)
@@ -282,30 +389,40 @@ func run() error {
 		return nil
 	}
 
+	if c.Watch {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		return rest.Watch(ctx, c.File, runRequests)
+	}
+
+	if c.Label == "" && c.Block < 0 && f.Parser.Root.CLI != nil {
+		cliBlock, err := f.Parser.CLI()
+		if err != nil {
+			return err
+		}
+		reserved := getReservedFlags(a)
+		for name := range cliBlock.Flags {
+			if reserved[name] {
+				return fmt.Errorf("cli flag %q conflicts with a built-in flag", name)
+			}
+		}
+		cliFlagValues := parseCLIFlagsFromArgs(cliBlock.Flags, reserved)
+		return runCLITool(f, cliBlock, cliFlagValues)
+	}
+	return runRequests(f)
+}
+
+// runRequests runs the selected block, label or the whole file
+func runRequests(f *rest.Rest) error {
 	if c.Block >= 0 {
 		log.Debug("running block", c.Block, "on file", c.File)
 		return f.RunIndex(c.Block)
 	} else if c.Label != "" {
 		log.Debug("running request", c.Label, "on file", c.File)
 		return f.RunLabel(c.Label)
-	} else {
-		if f.Parser.Root.CLI != nil {
-			cliBlock, err := f.Parser.CLI()
-			if err != nil {
-				return err
-			}
-			reserved := getReservedFlags(a)
-			for name := range cliBlock.Flags {
-				if reserved[name] {
-					return fmt.Errorf("cli flag %q conflicts with a built-in flag", name)
-				}
-			}
-			cliFlagValues := parseCLIFlagsFromArgs(cliBlock.Flags, reserved)
-			return runCLITool(f, cliBlock, cliFlagValues)
-		}
-		log.Debug("running file", c.File)
-		return f.RunFile(c.IgnoreFail)
 	}
+	log.Debug("running file", c.File)
+	return f.RunFile(c.IgnoreFail)
 }
 
 func getReservedFlags(a args.App) map[string]bool {
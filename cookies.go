@@ -0,0 +1,51 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/taybart/log"
+	"github.com/taybart/rest/client"
+)
+
+// CookieJar opens the jar configured with cookie_file
+func (rest *Rest) CookieJar() (*client.Jar, error) {
+	if rest.Parser.Config.CookieFile == "" {
+		return nil, errors.New("no cookie_file set in config block")
+	}
+	return client.OpenJar(rest.Parser.Config.CookieFile)
+}
+
+// Cookies prints the cookies stored in the jar
+func (rest *Rest) Cookies() error {
+	jar, err := rest.CookieJar()
+	if err != nil {
+		return err
+	}
+	cookies := jar.List()
+	if len(cookies) == 0 {
+		fmt.Println("no cookies in", jar.File)
+		return nil
+	}
+	for _, c := range cookies {
+		domain := c.Domain
+		if !c.HostOnly {
+			domain = "." + domain
+		}
+		expires := "session"
+		if !c.Expires.IsZero() {
+			expires = c.Expires.Local().Format(time.DateTime)
+		}
+		fmt.Printf("%s%s%s%s  %s=%s  (%s", log.Blue, domain, c.Path, log.Reset,
+			c.Name, c.Value, expires)
+		if c.Secure {
+			fmt.Print(", secure")
+		}
+		if c.HttpOnly {
+			fmt.Print(", httponly")
+		}
+		fmt.Println(")")
+	}
+	return nil
+}
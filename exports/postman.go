@@ -1,12 +1,19 @@
 package exports
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"maps"
+	"net/url"
 	"os"
+	"slices"
 	"sort"
 	"strings"
 
 	"github.com/taybart/rest/exports/postman"
+	"github.com/taybart/rest/exports/templates"
 	"github.com/taybart/rest/file"
 	"github.com/taybart/rest/request"
 	"github.com/taybart/rest/server"
@@ -70,6 +77,8 @@ func parseFile(filename string) (*restFile, error) {
 	return rest, nil
 }
 
+// ToPostmanCollection writes the requests of a file as a v2.1 collection,
+// locals become collection variables and namespaced imports become folders
 func ToPostmanCollection(filename, label string, block int) error {
 	rest, err := parseFile(filename)
 	if err != nil {
@@ -77,43 +86,46 @@ func ToPostmanCollection(filename, label string, block int) error {
 	}
 	c := postman.CreateCollection(filename, "collection")
 
-	for _, r := range rest.Requests {
-		item := &postman.Items{
-			Name: r.Label,
-			Request: &postman.Request{
-				URL:    postman.MustURL(r.URL),
-				Method: postman.Method(r.Method),
-				Body: &postman.Body{
-					Mode: "raw",
-					Raw:  r.Body,
-				},
-			},
+	locals := rest.Parser.StringLocals()
+	for _, name := range slices.Sorted(maps.Keys(locals)) {
+		c.Variables = append(c.Variables, &postman.Variable{
+			Key:   name,
+			Value: locals[name],
+			Type:  "string",
+		})
+	}
+	vars := func(s string) string {
+		replaced, _ := templates.ReplaceLocals(s, locals, func(name string) string {
+			return "{{" + name + "}}"
+		}, func(s string) string { return s })
+		return replaced
+	}
+
+	folders := map[string]*postman.Items{}
+	for _, hreq := range rest.ordered() {
+		if label != "" && hreq.Label != label {
+			continue
 		}
-		for k, v := range r.Headers {
-			item.Request.Header = append(item.Request.Header, &postman.Header{Key: k, Value: v})
+		if block >= 0 && hreq.BlockIndex != block {
+			continue
 		}
-		query := make([]*postman.QueryParam, len(r.Query))
-		i := 0
-		for k, v := range r.Query {
-			query[i] = &postman.QueryParam{Key: k, Value: v}
-			i += 1
+		r := rest.Requests[hreq.Label]
+		name := r.Label
+		namespace, short, namespaced := strings.Cut(r.Label, "::")
+		if namespaced {
+			name = short
 		}
-		item.Request.URL.Query = query
-		if r.BasicAuth != "" {
-			ba := strings.Split(r.BasicAuth, ":")
-			item.Request.Auth = postman.CreateAuth(
-				postman.Basic,
-				postman.CreateAuthParam("username", ba[0]),
-				postman.CreateAuthParam("password", ba[1]),
-			)
+		item := postmanItem(name, r, vars)
+		if !namespaced {
+			c.AddItem(item)
+			continue
 		}
-		if r.BearerToken != "" {
-			item.Request.Auth = postman.CreateAuth(
-				postman.Bearer,
-				postman.CreateAuthParam("token", r.BearerToken),
-			)
+		folder, ok := folders[namespace]
+		if !ok {
+			folder = c.AddItemGroup(namespace)
+			folders[namespace] = folder
 		}
-		c.AddItem(item)
+		folder.AddItem(item)
 	}
 
 	if err := c.Write(os.Stdout, postman.V210); err != nil {
@@ -122,3 +134,171 @@ func ToPostmanCollection(filename, label string, block int) error {
 
 	return nil
 }
+
+// ordered returns the request blocks in file order
+func (rest *restFile) ordered() []*file.HCLRequest {
+	blocks := slices.Collect(maps.Values(rest.HCLRequests))
+	slices.SortFunc(blocks, func(a, b *file.HCLRequest) int {
+		return a.BlockIndex - b.BlockIndex
+	})
+	return blocks
+}
+
+// postmanItem converts a request, vars replaces local values with postman
+// variables
+func postmanItem(name string, r request.Request, vars func(string) string) *postman.Items {
+	method := r.Method
+	if method == "" {
+		method = "GET"
+	}
+	item := &postman.Items{
+		Name: name,
+		Request: &postman.Request{
+			URL:    postmanURL(r, vars),
+			Method: postman.Method(method),
+			Body:   postmanBody(r, vars),
+		},
+	}
+	for _, k := range slices.Sorted(maps.Keys(r.Headers)) {
+		item.Request.Header = append(item.Request.Header, &postman.Header{Key: k, Value: vars(r.Headers[k])})
+	}
+	if len(r.Cookies) > 0 {
+		cookies := []string{}
+		for _, k := range slices.Sorted(maps.Keys(r.Cookies)) {
+			cookies = append(cookies, k+"="+r.Cookies[k])
+		}
+		item.Request.Header = append(item.Request.Header, &postman.Header{Key: "Cookie", Value: vars(strings.Join(cookies, "; "))})
+	}
+	if r.BasicAuth != "" {
+		username, password, _ := strings.Cut(r.BasicAuth, ":")
+		item.Request.Auth = postman.CreateAuth(
+			postman.Basic,
+			postman.CreateAuthParam("username", vars(username)),
+			postman.CreateAuthParam("password", vars(password)),
+		)
+	}
+	if r.BearerToken != "" {
+		item.Request.Auth = postman.CreateAuth(
+			postman.Bearer,
+			postman.CreateAuthParam("token", vars(r.BearerToken)),
+		)
+	}
+	if script := postmanTests(r); len(script) > 0 {
+		item.Events = append(item.Events, postman.CreateEvent(postman.Test, script))
+	}
+	return item
+}
+
+func postmanURL(r request.Request, vars func(string) string) *postman.URL {
+	raw := vars(r.URL)
+	u := &postman.URL{Raw: raw}
+	rest := raw
+	if protocol, after, ok := strings.Cut(rest, "://"); ok {
+		u.Protocol = protocol
+		rest = after
+	}
+	rest, _, _ = strings.Cut(rest, "?")
+	host, path, _ := strings.Cut(rest, "/")
+	if h, port, ok := strings.Cut(host, ":"); ok && !strings.Contains(port, "}") {
+		host, u.Port = h, port
+	}
+	u.Host = strings.Split(host, ".")
+	if path != "" {
+		u.Path = strings.Split(path, "/")
+	}
+
+	if len(r.Query) > 0 {
+		query := []string{}
+		for _, k := range slices.Sorted(maps.Keys(r.Query)) {
+			value := vars(r.Query[k])
+			u.Query = append(u.Query, &postman.QueryParam{Key: k, Value: value})
+			query = append(query, k+"="+value)
+		}
+		sep := "?"
+		if strings.Contains(u.Raw, "?") {
+			sep = "&"
+		}
+		u.Raw += sep + strings.Join(query, "&")
+	}
+	return u
+}
+
+type postmanField struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Type  string `json:"type"`
+}
+
+// postmanBody picks the body mode from the content type, form bodies are
+// split into fields and json bodies get the json language hint
+func postmanBody(r request.Request, vars func(string) string) *postman.Body {
+	if r.Body == "" || r.Body == "null" {
+		return nil
+	}
+	contentType := ""
+	for k, v := range r.Headers {
+		if strings.EqualFold(k, "Content-Type") {
+			contentType = v
+		}
+	}
+	if strings.Contains(contentType, "x-www-form-urlencoded") {
+		if values, err := url.ParseQuery(r.Body); err == nil {
+			fields := []postmanField{}
+			for _, k := range slices.Sorted(maps.Keys(values)) {
+				for _, v := range values[k] {
+					fields = append(fields, postmanField{Key: k, Value: vars(v), Type: "text"})
+				}
+			}
+			return &postman.Body{Mode: "urlencoded", URLEncoded: fields}
+		}
+	}
+	body := &postman.Body{Mode: "raw", Raw: vars(r.Body)}
+	var indented bytes.Buffer
+	if (contentType == "" || strings.Contains(contentType, "json")) &&
+		json.Indent(&indented, []byte(r.Body), "", "  ") == nil {
+		body.Raw = vars(indented.String())
+		body.Options = &postman.BodyOptions{Raw: postman.BodyOptionsRaw{Language: postman.JSON}}
+	}
+	return body
+}
+
+// postmanTests turns expect into pm.test assertions, after hooks are kept as
+// comments since they are lua
+func postmanTests(r request.Request) []string {
+	quote := func(s string) string {
+		b, _ := json.Marshal(s)
+		return string(b)
+	}
+	script := []string{}
+	status := r.ExpectStatus
+	if r.Expect != nil {
+		status = r.Expect.Status
+	}
+	if status != 0 {
+		script = append(script,
+			fmt.Sprintf(`pm.test("status is %d", function () {`, status),
+			fmt.Sprintf("    pm.response.to.have.status(%d);", status),
+			"});")
+	}
+	if r.Expect != nil {
+		for _, k := range slices.Sorted(maps.Keys(r.Expect.Headers)) {
+			script = append(script,
+				fmt.Sprintf("pm.test(%s, function () {", quote("header "+k+" is "+r.Expect.Headers[k])),
+				fmt.Sprintf("    pm.response.to.have.header(%s, %s);", quote(k), quote(r.Expect.Headers[k])),
+				"});")
+		}
+		if r.Expect.Body != "" {
+			script = append(script,
+				`pm.test("body matches", function () {`,
+				fmt.Sprintf("    pm.expect(pm.response.text()).to.eql(%s);", quote(r.Expect.Body)),
+				"});")
+		}
+	}
+	if after := strings.TrimSpace(r.After); after != "" {
+		script = append(script, "// rest after hook (lua), port to javascript:")
+		for line := range strings.SplitSeq(after, "\n") {
+			script = append(script, "// "+strings.TrimSpace(line))
+		}
+	}
+	return script
+}
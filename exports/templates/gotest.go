@@ -0,0 +1,22 @@
+package templates
+
+import (
+	_ "embed"
+)
+
+//go:embed gotest/client.tmpl
+var goTestClient string
+
+//go:embed gotest/function.tmpl
+var goTestFunction string
+
+//go:embed gotest/request.tmpl
+var goTestRequest string
+
+// GoTest : _test.go file with a test per request
+var GoTest = RequestTemplate{
+	Name:        "go-test",
+	ClientStr:   goTestClient,
+	FunctionStr: goTestFunction,
+	RequestStr:  goTestRequest,
+}
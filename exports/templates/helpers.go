@@ -2,8 +2,11 @@ package templates
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	"maps"
 	"regexp"
+	"slices"
 	"strings"
 	"text/template"
 
@@ -14,6 +17,10 @@ import (
 )
 
 var stdFns = template.FuncMap{
+	// locals is replaced with the file's locals when a client is executed
+	"locals": func() map[string]string {
+		return nil
+	},
 	"cty": func(value cty.Value) string {
 		return value.AsString()
 	},
@@ -30,6 +37,77 @@ var stdFns = template.FuncMap{
 		}
 		return s
 	},
+	"snakecase": func(s string) string {
+		s = regexp.MustCompile("[^a-zA-Z0-9]+").ReplaceAllString(s, "_")
+		s = regexp.MustCompile("([a-z0-9])([A-Z])").ReplaceAllString(s, "${1}_${2}")
+		s = strings.Trim(strings.ToLower(s), "_")
+		if s == "" || (s[0] >= '0' && s[0] <= '9') {
+			s = "r_" + s
+		}
+		return s
+	},
+	"pascalcase": func(s string) string {
+		s = regexp.MustCompile("[^a-zA-Z0-9]+").ReplaceAllString(s, " ")
+		s = cases.Title(language.AmericanEnglish, cases.NoLower).String(s)
+		return strings.ReplaceAll(s, " ", "")
+	},
+	"indent": func(prefix, s string) string {
+		lines := strings.Split(s, "\n")
+		for i, line := range lines {
+			if line != "" {
+				lines[i] = prefix + line
+			}
+		}
+		return strings.Join(lines, "\n")
+	},
+	// quote is a double quoted string that python, js, rust and go all accept
+	"quote": func(s string) string {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(s) + `"`
+	},
+	// squote is a single quoted shell string
+	"squote": func(s string) string {
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	},
+	// psquote is a single quoted powershell string
+	"psquote": func(s string) string {
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	},
+	// rustraw is a rust raw string with enough #s to hold s
+	"rustraw": func(s string) string {
+		hashes := "#"
+		for strings.Contains(s, `"`+hashes) {
+			hashes += "#"
+		}
+		return "r" + hashes + `"` + s + `"` + hashes
+	},
+	"b64": func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	},
+	"cookies": func(cookies map[string]string) string {
+		pairs := []string{}
+		for _, k := range slices.Sorted(maps.Keys(cookies)) {
+			pairs = append(pairs, k+"="+cookies[k])
+		}
+		return strings.Join(pairs, "; ")
+	},
+	// allheaders merges the user agent and bearer token (and basic auth when
+	// basic is true) into the request headers for clients without auth helpers
+	"allheaders": func(r Request, basic ...bool) map[string]string {
+		headers := maps.Clone(r.Headers)
+		if headers == nil {
+			headers = map[string]string{}
+		}
+		if r.UserAgent != "" {
+			headers["User-Agent"] = r.UserAgent
+		}
+		if r.BearerToken != "" {
+			headers["Authorization"] = "Bearer " + r.BearerToken
+		}
+		if r.BasicAuth != "" && len(basic) > 0 && basic[0] {
+			headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(r.BasicAuth))
+		}
+		return headers
+	},
 	"json": func(headers map[string]string, body string) string {
 		if headers["Content-Type"] == "application/json" {
 			var b bytes.Buffer
@@ -43,3 +121,48 @@ var stdFns = template.FuncMap{
 		return body
 	},
 }
+
+// shorter locals are not replaced, they match too much by accident
+const minLocalLength = 4
+
+// ReplaceLocals replaces occurrences of local values in s with ref(name), the
+// rest of s is passed through escape. It reports whether anything was replaced
+func ReplaceLocals(s string, locals map[string]string, ref, escape func(string) string) (string, bool) {
+	names := []string{}
+	for name, value := range locals {
+		if len(value) >= minLocalLength {
+			names = append(names, name)
+		}
+	}
+	// longest values first so a local containing another wins
+	slices.SortFunc(names, func(a, b string) int {
+		if len(locals[a]) != len(locals[b]) {
+			return len(locals[b]) - len(locals[a])
+		}
+		return strings.Compare(a, b)
+	})
+
+	var b strings.Builder
+	replaced := false
+	start := 0
+	for i := 0; i < len(s); {
+		match := ""
+		for _, name := range names {
+			if strings.HasPrefix(s[i:], locals[name]) {
+				match = name
+				break
+			}
+		}
+		if match == "" {
+			i++
+			continue
+		}
+		b.WriteString(escape(s[start:i]))
+		b.WriteString(ref(match))
+		replaced = true
+		i += len(locals[match])
+		start = i
+	}
+	b.WriteString(escape(s[start:]))
+	return b.String(), replaced
+}
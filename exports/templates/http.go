@@ -0,0 +1,59 @@
+package templates
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+//go:embed http/client.tmpl
+var httpClient string
+
+//go:embed http/request.tmpl
+var httpRequest string
+
+// HTTP : template for jetbrains/vscode .http files, locals become @vars
+var HTTP = RequestTemplate{
+	Name:        "http",
+	ClientStr:   httpClient,
+	FunctionStr: "{{.Code}}\n\n",
+	RequestStr:  httpRequest,
+	FuncMap: template.FuncMap{
+		"httpvars": httpVars,
+		// @name only allows identifiers
+		"httpname": func(label string) string {
+			return regexp.MustCompile(`[^A-Za-z0-9_-]+`).ReplaceAllString(label, "_")
+		},
+		"indentjson": func(headers map[string]string, body string) string {
+			if !strings.Contains(headers["Content-Type"], "json") {
+				return body
+			}
+			var b bytes.Buffer
+			if err := json.Indent(&b, []byte(body), "", "  "); err != nil {
+				return body
+			}
+			return b.String()
+		},
+		"comment": func(prefix, src string) string {
+			lines := strings.Split(strings.TrimSpace(src), "\n")
+			for i, line := range lines {
+				lines[i] = strings.TrimRight(prefix+strings.TrimSpace(line), " ")
+			}
+			return strings.Join(lines, "\n")
+		},
+	},
+}
+
+// httpVars replaces occurrences of locals in s with {{name}}
+func httpVars(locals map[string]string, s string) string {
+	replaced, ok := ReplaceLocals(s, locals, func(name string) string {
+		return "{{" + name + "}}"
+	}, func(s string) string { return s })
+	if !ok {
+		return s
+	}
+	return replaced
+}
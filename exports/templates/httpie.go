@@ -0,0 +1,22 @@
+package templates
+
+import (
+	_ "embed"
+)
+
+//go:embed httpie/client.tmpl
+var httpieClient string
+
+//go:embed httpie/function.tmpl
+var httpieFunction string
+
+//go:embed httpie/request.tmpl
+var httpieRequest string
+
+// HTTPie : shell script using httpie
+var HTTPie = RequestTemplate{
+	Name:        "httpie",
+	ClientStr:   httpieClient,
+	FunctionStr: httpieFunction,
+	RequestStr:  httpieRequest,
+}
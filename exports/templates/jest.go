@@ -0,0 +1,22 @@
+package templates
+
+import (
+	_ "embed"
+)
+
+//go:embed jest/client.tmpl
+var jestClient string
+
+//go:embed jest/function.tmpl
+var jestFunction string
+
+//go:embed jest/request.tmpl
+var jestRequest string
+
+// Jest : test file with a test per request using fetch
+var Jest = RequestTemplate{
+	Name:        "jest",
+	ClientStr:   jestClient,
+	FunctionStr: jestFunction,
+	RequestStr:  jestRequest,
+}
@@ -0,0 +1,56 @@
+package templates
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed k6/client.tmpl
+var k6Client string
+
+//go:embed k6/function.tmpl
+var k6Function string
+
+//go:embed k6/request.tmpl
+var k6Request string
+
+// K6 : load test script, requests are grouped by label
+var K6 = RequestTemplate{
+	Name:        "k6",
+	ClientStr:   k6Client,
+	FunctionStr: k6Function,
+	RequestStr:  k6Request,
+	FuncMap: template.FuncMap{
+		"k6const": k6Const,
+		"k6str":   k6Str,
+		"seconds": func(d string) string {
+			duration, err := time.ParseDuration(d)
+			if err != nil {
+				return "0"
+			}
+			return fmt.Sprint(duration.Seconds())
+		},
+		"canonicalheader": http.CanonicalHeaderKey,
+	},
+}
+
+func k6Const(name string) string {
+	return strings.ToUpper(stdFns["snakecase"].(func(string) string)(name))
+}
+
+// k6Str returns s as a js string, occurrences of locals are replaced with
+// their constants in a template literal
+func k6Str(locals map[string]string, s string) string {
+	escape := strings.NewReplacer("\\", `\\`, "`", "\\`", "${", `\${`, "\n", `\n`, "\r", `\r`).Replace
+	replaced, ok := ReplaceLocals(s, locals, func(name string) string {
+		return "${" + k6Const(name) + "}"
+	}, escape)
+	if !ok {
+		return stdFns["quote"].(func(string) string)(s)
+	}
+	return "`" + replaced + "`"
+}
@@ -0,0 +1,22 @@
+package templates
+
+import (
+	_ "embed"
+)
+
+//go:embed powershell/client.tmpl
+var powershellClient string
+
+//go:embed powershell/function.tmpl
+var powershellFunction string
+
+//go:embed powershell/request.tmpl
+var powershellRequest string
+
+// PowerShell : script using Invoke-WebRequest
+var PowerShell = RequestTemplate{
+	Name:        "powershell",
+	ClientStr:   powershellClient,
+	FunctionStr: powershellFunction,
+	RequestStr:  powershellRequest,
+}
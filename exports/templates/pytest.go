@@ -0,0 +1,22 @@
+package templates
+
+import (
+	_ "embed"
+)
+
+//go:embed pytest/client.tmpl
+var pytestClient string
+
+//go:embed pytest/function.tmpl
+var pytestFunction string
+
+//go:embed pytest/request.tmpl
+var pytestRequest string
+
+// Pytest : test module with a test per request using requests
+var Pytest = RequestTemplate{
+	Name:        "pytest",
+	ClientStr:   pytestClient,
+	FunctionStr: pytestFunction,
+	RequestStr:  pytestRequest,
+}
@@ -0,0 +1,22 @@
+package templates
+
+import (
+	_ "embed"
+)
+
+//go:embed python/client.tmpl
+var pythonClient string
+
+//go:embed python/function.tmpl
+var pythonFunction string
+
+//go:embed python/request.tmpl
+var pythonRequest string
+
+// Python : template using requests
+var Python = RequestTemplate{
+	Name:        "python",
+	ClientStr:   pythonClient,
+	FunctionStr: pythonFunction,
+	RequestStr:  pythonRequest,
+}
@@ -0,0 +1,22 @@
+package templates
+
+import (
+	_ "embed"
+)
+
+//go:embed rust/client.tmpl
+var rustClient string
+
+//go:embed rust/function.tmpl
+var rustFunction string
+
+//go:embed rust/request.tmpl
+var rustRequest string
+
+// Rust : client using blocking reqwest
+var Rust = RequestTemplate{
+	Name:        "rust",
+	ClientStr:   rustClient,
+	FunctionStr: rustFunction,
+	RequestStr:  rustRequest,
+}
@@ -5,16 +5,27 @@ import (
 	"bytes"
 	"go/format"
 	"io"
+	"maps"
 	"net/url"
+	"slices"
 	"strings"
 	"text/template"
 )
 
 var (
 	exports = map[string]RequestTemplate{
-		"go":   Go,
-		"curl": Curl,
-		"js":   Javascript,
+		"go":         Go,
+		"curl":       Curl,
+		"js":         Javascript,
+		"http":       HTTP,
+		"python":     Python,
+		"httpie":     HTTPie,
+		"powershell": PowerShell,
+		"rust":       Rust,
+		"go-test":    GoTest,
+		"jest":       Jest,
+		"pytest":     Pytest,
+		"k6":         K6,
 	}
 )
 
@@ -38,6 +49,9 @@ type Request struct {
 	After     string
 	UserAgent string
 
+	BasicAuth   string
+	BearerToken string
+
 	// extras
 	Label  string
 	Delay  string
@@ -97,10 +111,23 @@ func (r *RequestTemplate) Build() *RequestTemplate {
 	return r
 }
 
-func (r *RequestTemplate) Execute(wr io.Writer, filename string, reqs map[string]Request) error {
+// Execute renders every request into a client, locals are the string locals
+// of the file and are available to templates through the locals func
+func (r *RequestTemplate) Execute(wr io.Writer, filename string, reqs map[string]Request, locals map[string]string) error {
 	// req.Build()
+	localsFn := template.FuncMap{"locals": func() map[string]string { return locals }}
+	r.Client.Funcs(localsFn)
+	r.Request.Funcs(localsFn)
+	// keep the order of the rest file
+	labels := slices.SortedFunc(maps.Keys(reqs), func(a, b string) int {
+		if reqs[a].BlockIndex != reqs[b].BlockIndex {
+			return reqs[a].BlockIndex - reqs[b].BlockIndex
+		}
+		return strings.Compare(a, b)
+	})
 	var code bytes.Buffer
-	for label, req := range reqs {
+	for _, label := range labels {
+		req := reqs[label]
 		var reqBuf bytes.Buffer
 		err := r.executeRequest(&reqBuf, req)
 		if err != nil {
@@ -112,18 +139,14 @@ func (r *RequestTemplate) Execute(wr io.Writer, filename string, reqs map[string
 		})
 	}
 
-	labels := []string{}
-	for _, req := range reqs {
-		labels = append(labels, req.Label)
-	}
-
 	var client bytes.Buffer
 	r.Client.Execute(&client, map[string]any{
 		"Filename": filename,
 		"Code":     code.String(),
 		"Labels":   labels,
+		"Locals":   locals,
 	})
-	if r.Name == "go" {
+	if r.Name == "go" || r.Name == "go-test" {
 		formatted, err := format.Source(client.Bytes())
 		if err != nil {
 			return err
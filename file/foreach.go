@@ -0,0 +1,113 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Each is the instance of a request block created by for_each, it is exposed
+// to the block as each.key and each.value
+type Each struct {
+	Label string
+	Key   string
+	Value cty.Value
+}
+
+func (e Each) ctyVal() cty.Value {
+	return cty.ObjectVal(map[string]cty.Value{
+		"key":   cty.StringVal(e.Key),
+		"value": e.Value,
+	})
+}
+
+var forEachSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{{Name: "for_each"}},
+}
+
+// expandForEach replaces request blocks that have a for_each with one block
+// per element labelled label[key]
+func (p *Parser) expandForEach() error {
+	expanded := make([]*HCLRequest, 0, len(p.Root.Requests))
+	for _, hreq := range p.Root.Requests {
+		content, _, diags := hreq.Body.PartialContent(forEachSchema)
+		if diags.HasErrors() {
+			p.writeDiags(diags)
+			return fmt.Errorf("error decoding request (%s)", hreq.Label)
+		}
+		attr, ok := content.Attributes["for_each"]
+		if !ok {
+			expanded = append(expanded, hreq)
+			continue
+		}
+		val, diags := attr.Expr.Value(p.Ctx)
+		if diags.HasErrors() {
+			p.writeDiags(diags)
+			return fmt.Errorf("error evaluating for_each in request (%s)", hreq.Label)
+		}
+		instances, err := eachInstances(hreq.Label, val)
+		if err != nil {
+			return fmt.Errorf("request (%s) for_each: %w", hreq.Label, err)
+		}
+		for _, each := range instances {
+			expanded = append(expanded, &HCLRequest{
+				shouldSkip: hreq.shouldSkip,
+				Label:      fmt.Sprintf("%s[%s]", hreq.Label, each.Key),
+				Body:       hreq.Body,
+				Each:       each,
+			})
+		}
+	}
+	p.Root.Requests = expanded
+	return nil
+}
+
+func eachInstances(label string, val cty.Value) ([]*Each, error) {
+	if val.IsNull() || !val.IsKnown() {
+		return nil, errors.New("value must be a known list, set or map")
+	}
+	ty := val.Type()
+	if !ty.IsListType() && !ty.IsTupleType() && !ty.IsSetType() &&
+		!ty.IsMapType() && !ty.IsObjectType() {
+		return nil, fmt.Errorf("value must be a list, set or map, got %s", ty.FriendlyName())
+	}
+
+	instances := []*Each{}
+	for it := val.ElementIterator(); it.Next(); {
+		k, v := it.Element()
+		var key string
+		switch {
+		case ty.IsSetType():
+			// sets have no keys so each.key is the value like terraform
+			if v.Type() != cty.String {
+				return nil, errors.New("sets must only contain strings")
+			}
+			key = v.AsString()
+		case k.Type() == cty.Number:
+			i, _ := k.AsBigFloat().Int64()
+			key = big.NewInt(i).String()
+		default:
+			key = k.AsString()
+		}
+		instances = append(instances, &Each{Label: label, Key: key, Value: v})
+	}
+	return instances, nil
+}
+
+// AddInstanceExportsCtx stores exports from a for_each instance under
+// exports[label][key] so instances don't clobber each other
+func (p *Parser) AddInstanceExportsCtx(each *Each, exports map[string]any) {
+	if len(exports) == 0 {
+		return
+	}
+	instances := map[string]cty.Value{}
+	if prev, ok := p.Exports[each.Label]; ok && prev.Type().IsObjectType() && prev.LengthInt() > 0 {
+		instances = prev.AsValueMap()
+	}
+	instances[each.Key] = cty.ObjectVal(exportsToCty(exports))
+	p.Exports[each.Label] = cty.ObjectVal(instances)
+	p.AddExportsCtx(nil)
+}
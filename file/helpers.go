@@ -1,9 +1,15 @@
 package file
 
 import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"math/rand/v2"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -11,9 +17,11 @@ import (
 	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/google/uuid"
 	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/taybart/rest/jwt"
 	"github.com/zclconf/go-cty/cty"
 	"github.com/zclconf/go-cty/cty/function"
 	"github.com/zclconf/go-cty/cty/gocty"
@@ -22,7 +30,8 @@ import (
 
 /*** Functions ***/
 
-func makeFileReadFunc() function.Function {
+// onRead is called with every path read so watchers can pick them up
+func makeFileReadFunc(onRead func(string)) function.Function {
 	return function.New(&function.Spec{
 		Params: []function.Parameter{
 			{
@@ -39,6 +48,7 @@ func makeFileReadFunc() function.Function {
 				home, _ := os.UserHomeDir()
 				path = filepath.Join(home, path[2:])
 			}
+			onRead(path)
 			val, err := os.ReadFile(path)
 			if err != nil {
 				return cty.StringVal(""), err
@@ -467,3 +477,203 @@ func replaceNamedPlaceholders(template string, values cty.Value) (cty.Value, err
 	// fmt.Println(result)
 	return cty.StringVal(result), nil
 }
+
+func makeHashFunc(hash func([]byte) []byte) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name:        "str",
+				Type:        cty.String,
+				AllowMarked: true,
+			},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			arg, _ := args[0].Unmark()
+			return cty.StringVal(hex.EncodeToString(hash([]byte(arg.AsString())))), nil
+		},
+	})
+}
+
+func makeMD5Func() function.Function {
+	return makeHashFunc(func(b []byte) []byte {
+		sum := md5.Sum(b)
+		return sum[:]
+	})
+}
+
+func makeSHA256Func() function.Function {
+	return makeHashFunc(func(b []byte) []byte {
+		sum := sha256.Sum256(b)
+		return sum[:]
+	})
+}
+
+func makeHMACSHA256Func() function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name:        "key",
+				Type:        cty.String,
+				AllowMarked: true,
+			},
+			{
+				Name:        "msg",
+				Type:        cty.String,
+				AllowMarked: true,
+			},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			key, _ := args[0].Unmark()
+			msg, _ := args[1].Unmark()
+			mac := hmac.New(sha256.New, []byte(key.AsString()))
+			mac.Write([]byte(msg.AsString()))
+			return cty.StringVal(hex.EncodeToString(mac.Sum(nil))), nil
+		},
+	})
+}
+
+func makeTimestampFunc() function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{},
+		Type:   function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			return cty.StringVal(time.Now().UTC().Format(time.RFC3339)), nil
+		},
+	})
+}
+
+func makeUnixNowFunc() function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{},
+		Type:   function.StaticReturnType(cty.Number),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			return cty.NumberIntVal(time.Now().Unix()), nil
+		},
+	})
+}
+
+func makeRandomIntFunc() function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name: "min",
+				Type: cty.Number,
+			},
+			{
+				Name: "max",
+				Type: cty.Number,
+			},
+		},
+		Type: function.StaticReturnType(cty.Number),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			var lo, hi int64
+			if err := gocty.FromCtyValue(args[0], &lo); err != nil {
+				return cty.NilVal, err
+			}
+			if err := gocty.FromCtyValue(args[1], &hi); err != nil {
+				return cty.NilVal, err
+			}
+			if hi < lo {
+				return cty.NilVal, function.NewArgErrorf(1, "max (%d) must be greater than min (%d)", hi, lo)
+			}
+			// the size of the range doesn't fit in an int64
+			n := hi - lo + 1
+			if n <= 0 {
+				return cty.NilVal, function.NewArgErrorf(1, "range from %d to %d is too large", lo, hi)
+			}
+			return cty.NumberIntVal(lo + rand.Int64N(n)), nil
+		},
+	})
+}
+
+func makeURLEncodeFunc() function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name:        "str",
+				Type:        cty.String,
+				AllowMarked: true,
+			},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			arg, _ := args[0].Unmark()
+			return cty.StringVal(url.QueryEscape(arg.AsString())), nil
+		},
+	})
+}
+
+func makeJWTSignFunc() function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name:             "claims",
+				Type:             cty.DynamicPseudoType,
+				AllowDynamicType: true,
+			},
+			{
+				Name:        "key",
+				Type:        cty.String,
+				AllowMarked: true,
+			},
+		},
+		VarParam: &function.Parameter{
+			Name: "alg",
+			Type: cty.String,
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			claimsJSON, err := ctyjson.Marshal(args[0], args[0].Type())
+			if err != nil {
+				return cty.StringVal(""), err
+			}
+			claims := map[string]any{}
+			if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+				return cty.StringVal(""), fmt.Errorf("claims must be an object: %w", err)
+			}
+			key, _ := args[1].Unmark()
+			// alg is optional, cty has no optional params so it is a varparam
+			if len(args) > 3 {
+				return cty.StringVal(""), function.NewArgErrorf(3, "jwt_sign takes at most 3 arguments, got %d", len(args))
+			}
+			alg := jwt.HS256
+			if len(args) == 3 {
+				alg = args[2].AsString()
+			}
+			token, err := jwt.Sign(claims, []byte(key.AsString()), alg)
+			if err != nil {
+				return cty.StringVal(""), err
+			}
+			return cty.StringVal(token), nil
+		},
+	})
+}
+
+func makeFileBase64Func(onRead func(string)) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name:        "path",
+				Type:        cty.String,
+				AllowMarked: true,
+			},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			pathArg, _ := args[0].Unmark()
+			path := pathArg.AsString()
+			if strings.HasPrefix(path, "~/") {
+				home, _ := os.UserHomeDir()
+				path = filepath.Join(home, path[2:])
+			}
+			onRead(path)
+			val, err := os.ReadFile(path)
+			if err != nil {
+				return cty.StringVal(""), err
+			}
+			return cty.StringVal(base64.StdEncoding.EncodeToString(val)), nil
+		},
+	})
+}
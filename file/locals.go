@@ -58,3 +58,15 @@ func (p *Parser) decodeLocalsBlock(block hcl.Body) hcl.Diagnostics {
 	}
 	return diags
 }
+
+// StringLocals returns the locals that are strings, exports use them since
+// other types have no common representation across languages
+func (p *Parser) StringLocals() map[string]string {
+	locals := map[string]string{}
+	for name, value := range p.Locals {
+		if value.IsKnown() && !value.IsNull() && value.Type() == cty.String {
+			locals[name] = value.AsString()
+		}
+	}
+	return locals
+}
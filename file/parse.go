@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"math/big"
 	"os"
 	"path"
@@ -16,6 +17,7 @@ import (
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/gohcl"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/taybart/rest/openapi"
 	"github.com/taybart/rest/request"
 	"github.com/taybart/rest/server"
 	"github.com/zclconf/go-cty/cty"
@@ -28,6 +30,8 @@ type HCLRequest struct {
 	Label      string   `hcl:"label,label"`
 	Body       hcl.Body `hcl:",remain"`
 	BlockIndex int
+	// set when the block was created from a for_each
+	Each *Each
 }
 
 type CLIFlag struct {
@@ -77,16 +81,12 @@ func (r *Root) Add(root *Root, config request.Config) {
 		if config.SkipImported {
 			req.shouldSkip = true
 		}
-		if !config.NamespaceImports {
-			r.Requests = append(r.Requests, req)
-			continue
-		}
-		for _, req := range root.Requests {
+		if config.NamespaceImports {
 			base := filepath.Base(root.filename)
 			namespace := strings.TrimSuffix(base, filepath.Ext(base))
 			req.Label = fmt.Sprintf("%s::%s", namespace, req.Label)
-			r.Requests = append(r.Requests, req)
 		}
+		r.Requests = append(r.Requests, req)
 	}
 }
 
@@ -97,6 +97,8 @@ type Parser struct {
 	Locals  map[string]cty.Value
 	Exports map[string]cty.Value
 	Config  request.Config
+	// files pulled in with read() or file_b64()
+	reads map[string]struct{}
 }
 
 func NewParser(filename string) (*Parser, error) {
@@ -108,6 +110,7 @@ func NewParser(filename string) (*Parser, error) {
 		Files:   map[string]*hcl.File{},
 		Locals:  map[string]cty.Value{},
 		Exports: map[string]cty.Value{},
+		reads:   map[string]struct{}{},
 	}
 
 	if err := p.read(filename, p.Root); err != nil {
@@ -115,7 +118,7 @@ func NewParser(filename string) (*Parser, error) {
 	}
 
 	if p.Root.Config != nil {
-		if err := p.decode(p.Root.Config.Body, &p.Config); err != nil {
+		if err := p.decode(p.Ctx, p.Root.Config.Body, &p.Config); err != nil {
 			return p, errors.New("error decoding config block")
 		}
 	}
@@ -130,7 +133,7 @@ func NewParser(filename string) (*Parser, error) {
 			// get settings from imported file
 			config := p.Config
 			if importedRest.Config != nil {
-				if err := p.decode(importedRest.Config.Body, &config); err != nil {
+				if err := p.decode(p.Ctx, importedRest.Config.Body, &config); err != nil {
 					return p, errors.New("error decoding config block")
 				}
 			}
@@ -140,6 +143,9 @@ func NewParser(filename string) (*Parser, error) {
 	if err := p.decodeLocals(); err != nil {
 		return p, err
 	}
+	if err := p.expandForEach(); err != nil {
+		return p, err
+	}
 
 	return p, nil
 }
@@ -225,7 +231,7 @@ func (p *Parser) Socket() (request.Socket, error) {
 	if p.Root.Socket == nil {
 		return sock, errors.New("socket block not found")
 	}
-	if err := p.decode(p.Root.Socket.Body, &sock); err != nil {
+	if err := p.decode(p.Ctx, p.Root.Socket.Body, &sock); err != nil {
 		return sock, errors.New("error decoding socket block")
 	}
 	if err := sock.ParseExtras(p.Ctx); err != nil {
@@ -239,11 +245,26 @@ func (p *Parser) Server() (server.Config, error) {
 	if p.Root.Server == nil {
 		return serv, errors.New("server block not found")
 	}
-	if err := p.decode(p.Root.Server.Body, &serv); err != nil {
+	if err := p.decode(p.Ctx, p.Root.Server.Body, &serv); err != nil {
 		return serv, errors.New("error decoding server block")
 	}
+	if err := serv.Chaos.Validate(); err != nil {
+		return serv, err
+	}
+	if err := server.ValidateScenarios(serv); err != nil {
+		return serv, err
+	}
+	if serv.OpenAPI != "" {
+		// specs live next to the rest file
+		if !filepath.IsAbs(serv.OpenAPI) {
+			serv.OpenAPI = filepath.Join(filepath.Dir(p.Root.filename), serv.OpenAPI)
+		}
+		if _, err := openapi.Load(serv.OpenAPI); err != nil {
+			return serv, fmt.Errorf("openapi: %w", err)
+		}
+	}
 	if serv.Response != nil {
-		b, err := p.marshalBody(serv.Response.BodyHCL)
+		b, err := p.marshalBody(p.Ctx, serv.Response.BodyHCL)
 		if err != nil {
 			return serv, err
 		}
@@ -251,13 +272,40 @@ func (p *Parser) Server() (server.Config, error) {
 	}
 	if len(serv.Handlers) != 0 {
 		for k, handler := range serv.Handlers {
+			if err := handler.Chaos.Validate(); err != nil {
+				return serv, fmt.Errorf("handler %s %s: %w", handler.Method, handler.Path, err)
+			}
+			if err := handler.ValidateWS(); err != nil {
+				return serv, fmt.Errorf("handler %s %s: %w", handler.Method, handler.Path, err)
+			}
+			if handler.SSE != nil {
+				b, err := p.marshalBody(p.Ctx, handler.SSE.EventsHCL)
+				if err != nil {
+					return serv, err
+				}
+				if b != "" {
+					if err := json.Unmarshal([]byte(b), &handler.SSE.Events); err != nil {
+						return serv, fmt.Errorf("handler %s %s: sse events: %w", handler.Method, handler.Path, err)
+					}
+				}
+				if err := handler.ValidateSSE(); err != nil {
+					return serv, fmt.Errorf("handler %s %s: %w", handler.Method, handler.Path, err)
+				}
+			}
 			if handler.Response != nil {
-				b, err := p.marshalBody(handler.Response.BodyHCL)
+				b, err := p.marshalBody(p.Ctx, handler.Response.BodyHCL)
 				if err != nil {
 					return serv, err
 				}
 				serv.Handlers[k].Response.Body = json.RawMessage(b)
 			}
+			if handler.Match != nil && handler.Match.BodyJSONHCL != nil {
+				b, err := p.marshalBody(p.Ctx, handler.Match.BodyJSONHCL)
+				if err != nil {
+					return serv, err
+				}
+				serv.Handlers[k].Match.BodyJSON = json.RawMessage(b)
+			}
 		}
 	}
 	return serv, nil
@@ -268,8 +316,16 @@ func (p *Parser) Request(hreq *HCLRequest) (request.Request, error) {
 		return request.Request{}, fmt.Errorf("request not found")
 	}
 
+	ctx := p.Ctx
+	if hreq.Each != nil {
+		// scope each.key/each.value to this instance only, p.Ctx is left alone
+		// so requests can be built concurrently
+		ctx = p.Ctx.NewChild()
+		ctx.Variables = map[string]cty.Value{"each": hreq.Each.ctyVal()}
+	}
+
 	req := request.Request{Label: hreq.Label, Block: &hreq.Body}
-	if err := p.decode(hreq.Body, &req); err != nil {
+	if err := p.decode(ctx, hreq.Body, &req); err != nil {
 		return req, fmt.Errorf("error decoding request hreq(%s)", hreq.Label)
 	}
 	if hreq.shouldSkip {
@@ -294,17 +350,17 @@ func (p *Parser) Request(hreq *HCLRequest) (request.Request, error) {
 	}
 
 	var err error
-	req.Body, err = p.marshalBody(req.BodyHCL)
+	req.Body, err = p.marshalBody(ctx, req.BodyHCL)
 	if err != nil {
 		return req, err
 	}
 	if req.Expect != nil {
-		req.Expect.Body, err = p.marshalBody(req.Expect.BodyHCL)
+		req.Expect.Body, err = p.marshalBody(ctx, req.Expect.BodyHCL)
 		if err != nil {
 			return req, err
 		}
 	}
-	if err := req.SetDefaults(p.Ctx); err != nil {
+	if err := req.SetDefaults(ctx); err != nil {
 		return req, err
 	}
 	// make body look nice if its json
@@ -358,6 +414,23 @@ func (p *Parser) Requests() (map[string]request.Request, error) {
 	return requests, nil
 }
 
+func (p *Parser) trackRead(path string) {
+	p.reads[path] = struct{}{}
+}
+
+// Dependencies returns the rest file, its imports and every file read so far
+func (p *Parser) Dependencies() []string {
+	deps := make([]string, 0, len(p.Files)+len(p.reads))
+	for filename := range p.Files {
+		deps = append(deps, filename)
+	}
+	for filename := range p.reads {
+		deps = append(deps, filename)
+	}
+	slices.Sort(deps)
+	return deps
+}
+
 func (p *Parser) updateLocalsContext() {
 	p.Ctx.Variables["locals"] = cty.ObjectVal(p.Locals)
 }
@@ -400,26 +473,37 @@ func (p *Parser) AddExportsCtx(exports map[string]any) {
 }
 
 func (p *Parser) makeContext() {
+	functions := stdlibFunctions()
+	maps.Copy(functions, map[string]function.Function{
+		"b64_dec":     makeBase64DecodeFunc(),
+		"b64_enc":     makeBase64EncodeFunc(),
+		"btmpl":       makeTemplateFunc(),
+		"env":         makeEnvFunc(),
+		"file_b64":    makeFileBase64Func(p.trackRead),
+		"form":        makeFormFunc(),
+		"hmac_sha256": makeHMACSHA256Func(),
+		"json_dec":    makeJSONDecodeFunc(),
+		"json_enc":    makeJSONEncodeFunc(),
+		"jwt_sign":    makeJWTSignFunc(),
+		"md5":         makeMD5Func(),
+		"nanoid":      makeNanoIDFunc(),
+		"random_int":  makeRandomIntFunc(),
+		"read":        makeFileReadFunc(p.trackRead),
+		"sha256":      makeSHA256Func(),
+		"timestamp":   makeTimestampFunc(),
+		"tmpl":        makeGoTemplateFunc(),
+		"try_exports": makeTryExportsFunc(p.Exports),
+		"trim":        makeTrimFunc(),
+		"unix_now":    makeUnixNowFunc(),
+		"urlencode":   makeURLEncodeFunc(),
+		"uuid":        makeUUIDFunc(),
+	})
 	p.Ctx = &hcl.EvalContext{
 		Variables: map[string]cty.Value{
 			"locals":  cty.ObjectVal(p.Locals),
 			"exports": cty.ObjectVal(p.Exports),
 		},
-		Functions: map[string]function.Function{
-			"b64_dec":     makeBase64DecodeFunc(),
-			"b64_enc":     makeBase64EncodeFunc(),
-			"btmpl":       makeTemplateFunc(),
-			"env":         makeEnvFunc(),
-			"form":        makeFormFunc(),
-			"json_dec":    makeJSONDecodeFunc(),
-			"json_enc":    makeJSONEncodeFunc(),
-			"nanoid":      makeNanoIDFunc(),
-			"read":        makeFileReadFunc(),
-			"tmpl":        makeGoTemplateFunc(),
-			"try_exports": makeTryExportsFunc(p.Exports),
-			"trim":        makeTrimFunc(),
-			"uuid":        makeUUIDFunc(),
-		},
+		Functions: functions,
 	}
 }
 
@@ -433,8 +517,10 @@ func (p Parser) writeDiags(diags hcl.Diagnostics) {
 	wr.WriteDiagnostics(diags)
 }
 
-func (p *Parser) decode(body hcl.Body, to any) error {
-	if diags := gohcl.DecodeBody(body, p.Ctx, to); diags.HasErrors() {
+// decode evaluates body with ctx, requests pass a child of p.Ctx so their
+// variables don't leak into it
+func (p *Parser) decode(ctx *hcl.EvalContext, body hcl.Body, to any) error {
+	if diags := gohcl.DecodeBody(body, ctx, to); diags.HasErrors() {
 		p.writeDiags(diags)
 		return errors.New("error decoding hcl body")
 	}
@@ -442,8 +528,8 @@ func (p *Parser) decode(body hcl.Body, to any) error {
 }
 
 // marshalBody turns hcl expressions into a formatted json blob or go string
-func (p *Parser) marshalBody(bodyHCL hcl.Expression) (string, error) {
-	bodyVal, diags := bodyHCL.Value(p.Ctx)
+func (p *Parser) marshalBody(ctx *hcl.EvalContext, bodyHCL hcl.Expression) (string, error) {
+	bodyVal, diags := bodyHCL.Value(ctx)
 	if diags.HasErrors() {
 		p.writeDiags(diags)
 		return "", errors.New("could not decode body")
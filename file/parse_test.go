@@ -3,6 +3,9 @@ package file_test
 import (
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/taybart/rest"
@@ -77,6 +80,34 @@ func TestImportParse(t *testing.T) {
 			headers["X-imported-local"])
 	}
 }
+func TestNamespaceImportParse(t *testing.T) {
+	dir := t.TempDir()
+	imported := `
+request "one" {
+  url = "http://localhost:8080/one"
+}
+request "two" {
+  url = "http://localhost:8080/two"
+}
+`
+	main := `
+imports = ["./users.rest"]
+config { namespace_imports = true }
+`
+	if err := os.WriteFile(filepath.Join(dir, "users.rest"), []byte(imported), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.rest"), []byte(main), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rest := parse(t, filepath.Join(dir, "main.rest"), 2)
+	for _, label := range []string{"users::one", "users::two"} {
+		if _, err := rest.Request(label); err != nil {
+			t.Fatal(label, err)
+		}
+	}
+}
+
 func TestSocketParse(t *testing.T) {
 	rest := parse(t, "../doc/examples/client/socket.rest", 0)
 	socket, err := rest.Parser.Socket()
@@ -109,6 +140,112 @@ func TestServerParse(t *testing.T) {
 	}
 }
 
+func TestServerChaosParse(t *testing.T) {
+	rest := parse(t, "../doc/examples/server/chaos.rest", 0)
+	config, err := rest.Parser.Server()
+	if err != nil {
+		t.Fatal(err)
+	}
+	chaos := config.Chaos
+	if chaos == nil || chaos.Latency != "200ms..2s" || chaos.ErrorRate != 0.1 || chaos.ErrorStatus != 503 ||
+		chaos.Seed == nil || *chaos.Seed != 42 {
+		t.Fatalf("unexpected server chaos %+v", chaos)
+	}
+	download := config.Handlers[0].Chaos
+	if download == nil || download.DropConnectionRate != 0.05 || download.SlowBodyBPS != 1024 {
+		t.Fatalf("unexpected handler chaos %+v", download)
+	}
+	if config.Handlers[1].Chaos != nil {
+		t.Fatal("expected /health to use the server chaos block")
+	}
+}
+
+func TestServerScenarioParse(t *testing.T) {
+	rest := parse(t, "../doc/examples/server/scenario.rest", 0)
+	config, err := rest.Parser.Server()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Scenarios) != 1 || config.Scenarios[0].Name != "checkout" || config.Scenarios[0].Initial != "pending" {
+		t.Fatalf("unexpected scenarios %+v", config.Scenarios)
+	}
+	pay := config.Handlers[2]
+	if pay.Scenario != "checkout" || pay.RequiredState != "pending" || pay.NewState != "paid" || config.KVFile != "kv.json" {
+		t.Fatalf("unexpected handler %+v", pay)
+	}
+}
+
+func TestServerOpenAPIParse(t *testing.T) {
+	rest := parse(t, "../doc/examples/server/openapi.rest", 0)
+	config, err := rest.Parser.Server()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// resolved next to the rest file
+	if config.OpenAPI != filepath.Join("..", "doc", "examples", "server", "openapi.yaml") ||
+		len(config.Handlers) != 1 || config.Handlers[0].Path != "/v1/users/{user_id}" {
+		t.Fatalf("unexpected config %+v", config)
+	}
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "missing.rest")
+	content := `
+server {
+  address = "localhost:18080"
+  openapi = "missing.yaml"
+}
+`
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parse(t, filename, 0).Parser.Server(); err == nil {
+		t.Fatal("expected a missing spec to fail")
+	}
+}
+
+func TestServerWebsocketParse(t *testing.T) {
+	rest := parse(t, "../doc/examples/server/websocket.rest", 0)
+	config, err := rest.Parser.Server()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prices := config.Handlers[1]
+	if !prices.WS || prices.Every != "1s" || !strings.Contains(prices.Fn, "on_tick") {
+		t.Fatalf("unexpected handler %+v", prices)
+	}
+}
+
+func TestServerSSEParse(t *testing.T) {
+	rest := parse(t, "../doc/examples/server/sse.rest", 0)
+	config, err := rest.Parser.Server()
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := config.Handlers[0].SSE
+	if !events.Repeat || events.Interval != "500ms" || len(events.Events) != 3 {
+		t.Fatalf("unexpected sse block %+v", events)
+	}
+	done := events.Events[2]
+	if done.Event != "status" || string(done.Data) != `{"state":"done"}` || done.Retry != 1000 {
+		t.Fatalf("unexpected event %+v", done)
+	}
+	if metrics := config.Handlers[1]; metrics.SSE == nil || metrics.Fn == "" {
+		t.Fatalf("unexpected handler %+v", metrics)
+	}
+}
+
+func TestServerTLSParse(t *testing.T) {
+	rest := parse(t, "../doc/examples/server/tls.rest", 0)
+	config, err := rest.Parser.Server()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.TLS != "auto" || config.TLSCA != "keys/rest-ca" ||
+		len(config.TLSHosts) != 1 || config.TLSClientCA != "keys/rest-ca.crt" {
+		t.Fatalf("unexpected config %+v", config)
+	}
+}
+
 func TestShebangParse(t *testing.T) {
 	tmpDir := t.TempDir()
 	filename := filepath.Join(tmpDir, "shebang.rest")
@@ -138,3 +275,168 @@ request "shebang" {
 		t.Fatalf("unexpected url: %s", req.URL)
 	}
 }
+
+func TestFunctionsParse(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "data.bin"), []byte{0xde, 0xad}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(tmpDir, "functions.rest")
+	content := `
+request "functions" {
+  url = "http://localhost:18080/${lower("HELLO")}?q=${urlencode("a b&c")}"
+  headers = {
+    "X-Sha" = sha256("abc")
+    "X-Hmac" = hmac_sha256("key", "msg")
+    "X-Md5" = md5("abc")
+    "X-Join" = join(",", split("-", "a-b-c"))
+    "X-Lookup" = lookup(merge({ a = "1" }, { b = "2" }), "b", "none")
+    "X-Coalesce" = coalesce(null, "fallback")
+    "X-Date" = formatdate("YYYY-MM-DD", timeadd("2024-01-01T00:00:00Z", "24h"))
+    "X-Random" = random_int(5, 5)
+    "X-File" = file_b64("` + filepath.Join(tmpDir, "data.bin") + `")
+  }
+  bearer_token = jwt_sign({ sub = "user" }, "secret")
+}
+`
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := rest.NewFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := r.Request("functions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.URL != "http://localhost:18080/hello?q=a+b%26c" {
+		t.Fatalf("unexpected url: %s", req.URL)
+	}
+	expected := map[string]string{
+		"X-Sha":      "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		"X-Hmac":     "2d93cbc1be167bcb1637a4a23cbff01a7878f0c50ee833954ea5221bb1b8c628",
+		"X-Md5":      "900150983cd24fb0d6963f7d28e17f72",
+		"X-Join":     "a,b,c",
+		"X-Lookup":   "2",
+		"X-Coalesce": "fallback",
+		"X-Date":     "2024-01-02",
+		"X-Random":   "5",
+		"X-File":     "3q0=",
+	}
+	for k, v := range expected {
+		if req.Headers[k] != v {
+			t.Fatalf("expected header %s to be %s got: %s", k, v, req.Headers[k])
+		}
+	}
+	if len(strings.Split(req.BearerToken, ".")) != 3 {
+		t.Fatalf("expected bearer token to be a jwt got: %s", req.BearerToken)
+	}
+}
+
+func TestFunctionArgErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+	tests := map[string]string{
+		"jwt_sign":   `jwt_sign({ sub = "user" }, "secret", "HS256", "extra")`,
+		"random_int": `random_int(-9223372036854775808, 9223372036854775807)`,
+		"reversed":   `random_int(5, 1)`,
+	}
+	for name, call := range tests {
+		filename := filepath.Join(tmpDir, name+".rest")
+		content := `
+request "functions" {
+  url = "http://localhost:18080/${` + call + `}"
+}
+`
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		r, err := rest.NewFile(filename)
+		if err == nil {
+			_, err = r.Request("functions")
+		}
+		if err == nil {
+			t.Fatalf("expected %s to fail", call)
+		}
+	}
+}
+
+func TestForEachParse(t *testing.T) {
+	tmpDir := t.TempDir()
+	csv := "id,name\n1,alice\n2,bob\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "users.csv"), []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(tmpDir, "for_each.rest")
+	content := `
+locals {
+  users = csvdecode(read("` + filepath.Join(tmpDir, "users.csv") + `"))
+}
+request "user" {
+  for_each = locals.users
+  url = "http://localhost:18080/user/${each.value.id}"
+  headers = { "X-Name" = each.value.name, "X-Key" = each.key }
+}
+request "env" {
+  for_each = { dev = "http://dev", prod = "http://prod" }
+  url = "${each.value}/${each.key}"
+}
+request "after" {
+  url = "http://localhost:18080/${exports.user["1"].id}"
+}
+`
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := rest.NewFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Requests) != 5 {
+		t.Fatalf("expected 5 requests, got %d", len(r.Requests))
+	}
+	req, err := r.Request("user[1]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.URL != "http://localhost:18080/user/2" {
+		t.Fatalf("unexpected url: %s", req.URL)
+	}
+	if req.Headers["X-Name"] != "bob" || req.Headers["X-Key"] != "1" {
+		t.Fatalf("unexpected headers: %v", req.Headers)
+	}
+	req, err = r.Request("env[prod]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.URL != "http://prod/prod" {
+		t.Fatalf("unexpected url: %s", req.URL)
+	}
+	// instances can be built at the same time without seeing each other's each
+	var wg sync.WaitGroup
+	for range 10 {
+		for label, url := range map[string]string{"user[0]": "http://localhost:18080/user/1", "env[dev]": "http://dev/dev"} {
+			wg.Go(func() {
+				if req, err := r.Request(label); err != nil || req.URL != url {
+					t.Errorf("%s: expected %s got %s %v", label, url, req.URL, err)
+				}
+			})
+		}
+	}
+	wg.Wait()
+	if instances := r.Instances("user"); len(instances) != 2 || instances[0] != "user[0]" {
+		t.Fatalf("unexpected instances: %v", instances)
+	}
+	if deps := r.Parser.Dependencies(); !slices.Contains(deps, filepath.Join(tmpDir, "users.csv")) {
+		t.Fatalf("expected read() file in dependencies: %v", deps)
+	}
+
+	r.AddExports("user[1]", map[string]any{"id": "exported"})
+	req, err = r.Request("after")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.URL != "http://localhost:18080/exported" {
+		t.Fatalf("unexpected url: %s", req.URL)
+	}
+}
@@ -0,0 +1,59 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/taybart/log"
+	"github.com/taybart/rest/history"
+)
+
+// History prints past runs of label, newest first, an empty label lists
+// every run of the file
+func (rest *Rest) History(label string) error {
+	entries, err := history.Open(rest.filename).List(label)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no history for %q", label)
+	}
+	for i, e := range entries {
+		status := "---"
+		if e.Response != nil {
+			status = fmt.Sprint(e.Response.Status)
+		}
+		fmt.Printf("%s%3d%s  %s  %s  %-8s %s %s",
+			log.Blue, i, log.Reset,
+			e.Time.Local().Format(time.DateTime), status,
+			e.Duration.Round(time.Millisecond), e.Request.Method, e.Request.URL)
+		if label == "" {
+			fmt.Printf("  (%s)", e.Label)
+		}
+		if e.Error != "" {
+			fmt.Printf("  %s%s%s", log.Red, e.Error, log.Reset)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// Diff prints a json diff between the latest run of label and the run at
+// index against (as listed by History)
+func (rest *Rest) Diff(label string, against int) error {
+	if label == "" {
+		return fmt.Errorf("diff requires a label")
+	}
+	entries, err := history.Open(rest.filename).List(label)
+	if err != nil {
+		return err
+	}
+	if against < 1 || against >= len(entries) {
+		return fmt.Errorf("%q has %d run(s), can't diff against %d", label, len(entries), against)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(history.Diff(entries[against], entries[0]))
+}
@@ -0,0 +1,115 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+type Op string
+
+const (
+	Added   Op = "added"
+	Removed Op = "removed"
+	Changed Op = "changed"
+)
+
+type Change struct {
+	Op   Op     `json:"op"`
+	Path string `json:"path"`
+	From any    `json:"from"`
+	To   any    `json:"to"`
+}
+
+// Diff compares the responses of two runs, json bodies are compared
+// structurally, anything else as a string
+func Diff(from, to Entry) []Change {
+	changes := []Change{}
+	a, b := from.Response, to.Response
+	if a == nil || b == nil {
+		if a != b {
+			changes = append(changes, Change{Op: Changed, Path: "response", From: a, To: b})
+		}
+		return changes
+	}
+	if a.Status != b.Status {
+		changes = append(changes, Change{Op: Changed, Path: "status", From: a.Status, To: b.Status})
+	}
+	changes = append(changes, diffValue("headers", headersToAny(a.Headers), headersToAny(b.Headers))...)
+	changes = append(changes, diffValue("body", bodyValue(a.Body), bodyValue(b.Body))...)
+	return changes
+}
+
+// headers that change on every response and would just be noise
+var volatileHeaders = []string{"Date"}
+
+func headersToAny(headers map[string][]string) map[string]any {
+	ret := map[string]any{}
+	for k, v := range headers {
+		if slices.Contains(volatileHeaders, k) {
+			continue
+		}
+		ret[k] = strings.Join(v, ", ")
+	}
+	return ret
+}
+
+func bodyValue(body string) any {
+	var v any
+	if err := json.Unmarshal([]byte(body), &v); err == nil {
+		return v
+	}
+	return body
+}
+
+func diffValue(path string, from, to any) []Change {
+	switch a := from.(type) {
+	case map[string]any:
+		b, ok := to.(map[string]any)
+		if !ok {
+			break
+		}
+		changes := []Change{}
+		keys := slices.Sorted(maps.Keys(a))
+		for _, k := range keys {
+			child := path + "." + k
+			bv, ok := b[k]
+			if !ok {
+				changes = append(changes, Change{Op: Removed, Path: child, From: a[k]})
+				continue
+			}
+			changes = append(changes, diffValue(child, a[k], bv)...)
+		}
+		for _, k := range slices.Sorted(maps.Keys(b)) {
+			if _, ok := a[k]; !ok {
+				changes = append(changes, Change{Op: Added, Path: path + "." + k, To: b[k]})
+			}
+		}
+		return changes
+	case []any:
+		b, ok := to.([]any)
+		if !ok {
+			break
+		}
+		changes := []Change{}
+		for i := range max(len(a), len(b)) {
+			child := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(b):
+				changes = append(changes, Change{Op: Removed, Path: child, From: a[i]})
+			case i >= len(a):
+				changes = append(changes, Change{Op: Added, Path: child, To: b[i]})
+			default:
+				changes = append(changes, diffValue(child, a[i], b[i])...)
+			}
+		}
+		return changes
+	}
+	if reflect.DeepEqual(from, to) {
+		return nil
+	}
+	return []Change{{Op: Changed, Path: path, From: from, To: to}}
+}
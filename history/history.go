@@ -0,0 +1,205 @@
+// Package history records executed requests next to their rest file so runs
+// can be listed and diffed later
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Dir is where history is kept relative to the rest file
+const Dir = ".rest/history"
+
+// DefaultMaxSize is the size a history file can grow to before the oldest
+// runs are dropped
+const DefaultMaxSize = 10 << 20
+
+// Redacted replaces the values of headers that hold credentials
+const Redacted = "[redacted]"
+
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// Redact copies headers with credentials replaced, that covers auth and
+// cookie headers along with anything ending in -Key or -Token
+func Redact(headers map[string][]string) map[string][]string {
+	if headers == nil {
+		return nil
+	}
+	ret := make(map[string][]string, len(headers))
+	for k, v := range headers {
+		canonical := http.CanonicalHeaderKey(k)
+		if slices.Contains(sensitiveHeaders, canonical) ||
+			strings.HasSuffix(canonical, "-Key") || strings.HasSuffix(canonical, "-Token") {
+			v = []string{Redacted}
+		}
+		ret[k] = v
+	}
+	return ret
+}
+
+type Request struct {
+	Method  string              `json:"method"`
+	URL     string              `json:"url"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	Body    string              `json:"body,omitempty"`
+}
+
+type Response struct {
+	Status  int                 `json:"status"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	Body    string              `json:"body,omitempty"`
+}
+
+type Entry struct {
+	Time     time.Time      `json:"time"`
+	File     string         `json:"file"`
+	Label    string         `json:"label"`
+	Duration time.Duration  `json:"duration"`
+	Request  Request        `json:"request"`
+	Response *Response      `json:"response,omitempty"`
+	Exports  map[string]any `json:"exports,omitempty"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// Store is a jsonl file per rest file
+type Store struct {
+	File string
+	// once the file is bigger than this the oldest runs are dropped until it
+	// is half the size, 0 never drops anything
+	MaxSize int64
+	path    string
+}
+
+func Open(restFile string) *Store {
+	base := filepath.Base(restFile)
+	return &Store{
+		File:    base,
+		MaxSize: DefaultMaxSize,
+		path:    filepath.Join(filepath.Dir(restFile), Dir, base+".jsonl"),
+	}
+}
+
+// ignore drops a .gitignore into .rest so recorded bodies don't get committed
+// by accident, an existing one is left alone
+func ignore(dir string) error {
+	f, err := os.OpenFile(filepath.Join(dir, ".gitignore"), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString("*\n")
+	return err
+}
+
+// Append records a run, credentials in the headers are redacted since the
+// file sits next to the rest file
+func (s *Store) Append(e Entry) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	if err := ignore(filepath.Dir(filepath.Dir(s.path))); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	e.File = s.File
+	e.Request.Headers = Redact(e.Request.Headers)
+	if e.Response != nil {
+		res := *e.Response
+		res.Headers = Redact(res.Headers)
+		e.Response = &res
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if s.MaxSize > 0 && info.Size() > s.MaxSize {
+		return s.trim()
+	}
+	return nil
+}
+
+// trim drops the oldest runs until the file is under half of MaxSize, the
+// newest run is always kept
+func (s *Store) trim() error {
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(string(b), "\n"), "\n")
+	size := int64(0)
+	keep := len(lines)
+	for keep > 0 && (keep == len(lines) || size+int64(len(lines[keep-1])) <= s.MaxSize/2) {
+		size += int64(len(lines[keep-1]))
+		keep--
+	}
+	trimmed := strings.Join(lines[keep:], "")
+	if !strings.HasSuffix(trimmed, "\n") {
+		trimmed += "\n"
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(trimmed), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// List returns the runs for label, newest first, an empty label returns
+// every run in the file
+func (s *Store) List(label string) ([]Entry, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := []Entry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			return nil, err
+		}
+		if label == "" || e.Label == label {
+			entries = append(entries, e)
+		}
+	}
+	slices.Reverse(entries)
+	return entries, scanner.Err()
+}
+
+func (s *Store) Clear() error {
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package history_test
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/taybart/rest/history"
+)
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	store := history.Open(filepath.Join(dir, "api.rest"))
+	for _, body := range []string{`{"a": 1, "list": [1, 2]}`, `{"a": 2, "list": [1], "b": true}`} {
+		err := store.Append(history.Entry{
+			Label:    "get",
+			Response: &history.Response{Status: 200, Body: body},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Append(history.Entry{Label: "other"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ignore, err := os.ReadFile(filepath.Join(dir, ".rest", ".gitignore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(ignore) != "*\n" {
+		t.Fatal("expected .rest to be ignored got:", string(ignore))
+	}
+
+	entries, err := store.List("get")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatal("expected 2 entries, got", len(entries))
+	}
+	if entries[0].File != "api.rest" {
+		t.Fatal("expected file to be api.rest got:", entries[0].File)
+	}
+
+	changes := history.Diff(entries[1], entries[0])
+	expected := []history.Change{
+		{Op: history.Changed, Path: "body.a"},
+		{Op: history.Removed, Path: "body.list[1]"},
+		{Op: history.Added, Path: "body.b"},
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("expected %d changes, got %+v", len(expected), changes)
+	}
+	for i, c := range changes {
+		if c.Op != expected[i].Op || c.Path != expected[i].Path {
+			t.Fatalf("expected change %+v got: %+v", expected[i], c)
+		}
+	}
+}
+
+func TestStoreRedactsAndTrims(t *testing.T) {
+	dir := t.TempDir()
+	store := history.Open(filepath.Join(dir, "api.rest"))
+	store.MaxSize = 2048
+	for i := range 20 {
+		err := store.Append(history.Entry{
+			Label: "get",
+			Request: history.Request{Headers: map[string][]string{
+				"Authorization": {"Bearer secret"},
+				"X-Api-Key":     {"secret"},
+				"Accept":        {"application/json"},
+			}},
+			Response: &history.Response{
+				Status:  200,
+				Headers: map[string][]string{"Set-Cookie": {"session=secret"}},
+				Body:    strings.Repeat("x", 100) + strconv.Itoa(i),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := store.List("get")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) == 0 || len(entries) == 20 {
+		t.Fatal("expected the oldest runs to be dropped, got", len(entries))
+	}
+	if !strings.HasSuffix(entries[0].Response.Body, "19") {
+		t.Fatal("expected the newest run to be kept got:", entries[0].Response.Body)
+	}
+	latest := entries[0]
+	for _, v := range [][]string{latest.Request.Headers["Authorization"], latest.Request.Headers["X-Api-Key"], latest.Response.Headers["Set-Cookie"]} {
+		if len(v) != 1 || v[0] != history.Redacted {
+			t.Fatal("expected header to be redacted got:", v)
+		}
+	}
+	if latest.Request.Headers["Accept"][0] != "application/json" {
+		t.Fatal("expected accept to be kept got:", latest.Request.Headers["Accept"])
+	}
+
+	info, err := os.Stat(filepath.Join(dir, history.Dir, "api.rest.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		t.Fatal("expected history file to be private got:", info.Mode())
+	}
+}
@@ -0,0 +1,329 @@
+package imports
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// boundary used for bodies built from curl -F
+const multipartBoundary = "rest-form-boundary"
+
+// curl flags that take a value but have no rest equivalent
+var curlIgnoredValueFlags = map[string]bool{
+	"-o": true, "--output": true, "-m": true, "--max-time": true,
+	"--connect-timeout": true, "-w": true, "--write-out": true,
+	"-x": true, "--proxy": true, "--retry": true, "-c": true,
+	"--cookie-jar": true, "--cacert": true, "--cert": true, "-E": true,
+	"--key": true, "-r": true, "--range": true, "-T": true,
+	"--upload-file": true, "--resolve": true, "--limit-rate": true,
+}
+
+// Curl converts a curl command (ex. from "copy as cURL" in browser devtools)
+// into a rest file with a single request
+func Curl(command string) (*File, error) {
+	words, err := shellWords(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(words) == 0 || filepath.Base(words[0]) != "curl" {
+		return nil, errors.New("not a curl command")
+	}
+
+	f := &File{}
+	var (
+		method, rawURL string
+		headers        []Header
+		data           []string
+		form           []string
+		get            bool
+		user           string
+	)
+	args := words[1:]
+	next := func(i *int, flag string) (string, error) {
+		*i++
+		if *i >= len(args) {
+			return "", fmt.Errorf("%s requires a value", flag)
+		}
+		return args[*i], nil
+	}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		flag, inline, hasInline := arg, "", false
+		// --flag=value and -Xvalue
+		if strings.HasPrefix(arg, "--") {
+			flag, inline, hasInline = strings.Cut(arg, "=")
+		} else if strings.HasPrefix(arg, "-") && len(arg) > 2 && strings.Contains("XHdubFAe", arg[1:2]) {
+			flag, inline, hasInline = arg[:2], arg[2:], true
+		}
+		value := func() (string, error) {
+			if hasInline {
+				return inline, nil
+			}
+			return next(&i, flag)
+		}
+
+		var v string
+		switch flag {
+		case "-X", "--request", "-H", "--header", "-d", "--data", "--data-raw",
+			"--data-binary", "--data-ascii", "--data-urlencode", "-u", "--user",
+			"-b", "--cookie", "-F", "--form", "--form-string", "-A", "--user-agent",
+			"-e", "--referer", "--url":
+			if v, err = value(); err != nil {
+				return nil, err
+			}
+		}
+		switch flag {
+		case "-X", "--request":
+			method = v
+		case "-H", "--header":
+			name, val, _ := strings.Cut(v, ":")
+			headers = append(headers, Header{Name: strings.TrimSpace(name), Value: strings.TrimSpace(val)})
+		case "-d", "--data", "--data-raw", "--data-binary", "--data-ascii":
+			data = append(data, v)
+		case "--data-urlencode":
+			name, val, ok := strings.Cut(v, "=")
+			if ok {
+				data = append(data, name+"="+url.QueryEscape(val))
+			} else {
+				data = append(data, url.QueryEscape(v))
+			}
+		case "-u", "--user":
+			user = v
+		case "-b", "--cookie":
+			if !strings.Contains(v, "=") {
+				// a cookie file, rest rewrites its cookie_file in its own format
+				// so curl's jar is left alone
+				f.Comment = fmt.Sprintf("curl read cookies from %s, copy them into a cookie_file with\n"+
+					"  rest cookies import %s -f <this file>\n"+
+					"after setting cookie_file in a config block", v, v)
+				continue
+			}
+			headers = append(headers, Header{Name: "Cookie", Value: v})
+		case "-F", "--form", "--form-string":
+			form = append(form, v)
+		case "-A", "--user-agent":
+			headers = append(headers, Header{Name: "User-Agent", Value: v})
+		case "-e", "--referer":
+			headers = append(headers, Header{Name: "Referer", Value: v})
+		case "--url":
+			rawURL = v
+		case "-G", "--get":
+			get = true
+		case "-I", "--head":
+			method = "HEAD"
+		case "-k", "--insecure":
+			if f.Config == nil {
+				f.Config = map[string]any{}
+			}
+			f.Config["insecure_no_verify_tls"] = true
+		case "--compressed":
+			// go asks for and decompresses gzip already
+		default:
+			if curlIgnoredValueFlags[flag] && !hasInline {
+				i++
+				continue
+			}
+			if !strings.HasPrefix(arg, "-") && rawURL == "" {
+				rawURL = arg
+			}
+		}
+	}
+	if rawURL == "" {
+		return nil, errors.New("no url in curl command")
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+
+	body := strings.Join(data, "&")
+	if get && body != "" {
+		sep := "?"
+		if strings.Contains(rawURL, "?") {
+			sep = "&"
+		}
+		rawURL += sep + body
+		body = ""
+	}
+	contentType := ""
+	for _, h := range headers {
+		if strings.EqualFold(h.Name, "Content-Type") {
+			contentType = h.Value
+		}
+	}
+	if body != "" && contentType == "" {
+		headers = append(headers, Header{Name: "Content-Type", Value: "application/x-www-form-urlencoded"})
+	}
+	if method == "" {
+		method = "GET"
+		if body != "" || len(form) > 0 {
+			method = "POST"
+		}
+	}
+
+	r := NewRequest(method, rawURL, headers, body)
+	if path, ok := strings.CutPrefix(body, "@"); ok && len(data) == 1 {
+		// -d @file sends the file contents
+		r.Body = fmt.Sprintf(`${read("%s")}`, Literal(path))
+	}
+	if user != "" {
+		r.BasicAuth = Literal(user)
+	}
+	if len(form) > 0 {
+		if r.Headers == nil {
+			r.Headers = map[string]string{}
+		}
+		r.Headers["Content-Type"] = "multipart/form-data; boundary=" + multipartBoundary
+		r.Body = multipart(form)
+	}
+	f.Add(r)
+	return f, nil
+}
+
+// multipart builds a multipart body template from curl -F name=value fields,
+// files (name=@path) are read with read()
+func multipart(fields []string) string {
+	var b strings.Builder
+	for _, field := range fields {
+		name, value, _ := strings.Cut(field, "=")
+		b.WriteString("--" + multipartBoundary + `\r\n`)
+		if path, ok := strings.CutPrefix(value, "@"); ok {
+			path, _, _ = strings.Cut(path, ";")
+			fmt.Fprintf(&b, `Content-Disposition: form-data; name=\"%s\"; filename=\"%s\"\r\n\r\n`,
+				Literal(name), Literal(filepath.Base(path)))
+			fmt.Fprintf(&b, `${read("%s")}\r\n`, Literal(path))
+			continue
+		}
+		value = strings.TrimPrefix(value, "<")
+		fmt.Fprintf(&b, `Content-Disposition: form-data; name=\"%s\"\r\n\r\n%s\r\n`, Literal(name), Literal(value))
+	}
+	b.WriteString("--" + multipartBoundary + `--\r\n`)
+	return b.String()
+}
+
+// shellWords splits a command like a posix shell would, including $'...'
+// quoting that browsers use in "copy as cURL"
+func shellWords(s string) ([]string, error) {
+	words := []string{}
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			// line continuation
+			if s[i] == '\n' || (s[i] == '\r' && i+1 < len(s) && s[i+1] == '\n') {
+				if s[i] == '\r' {
+					i++
+				}
+				continue
+			}
+			word.WriteByte(s[i])
+			inWord = true
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+			inWord = true
+		case c == '$' && i+1 < len(s) && s[i+1] == '\'':
+			n, err := ansiCQuoted(s[i+2:], &word)
+			if err != nil {
+				return nil, err
+			}
+			i += n + 1
+			inWord = true
+		case c == '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`\n", s[i+1]) >= 0 {
+					i++
+					if s[i] == '\n' {
+						continue
+					}
+				}
+				word.WriteByte(s[i])
+			}
+			if i >= len(s) {
+				return nil, errors.New("unterminated double quote")
+			}
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// ansiCQuoted reads the body of a $'...' string and returns how many bytes it
+// used including the closing quote
+func ansiCQuoted(s string, w *strings.Builder) (int, error) {
+	escapes := map[byte]byte{'n': '\n', 't': '\t', 'r': '\r', '\\': '\\', '\'': '\'', '"': '"', 'a': '\a', 'b': '\b', 'e': 0x1b, 'f': '\f', 'v': '\v', '0': 0}
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'':
+			return i + 1, nil
+		case '\\':
+			if i+1 >= len(s) {
+				return 0, errors.New("unterminated $' quote")
+			}
+			i++
+			if s[i] == 'x' || s[i] == 'u' {
+				size := 2
+				if s[i] == 'u' {
+					size = 4
+				}
+				var r rune
+				n := 0
+				for ; n < size && i+1+n < len(s) && isHex(s[i+1+n]); n++ {
+					r = r*16 + rune(hexVal(s[i+1+n]))
+				}
+				if s[i] == 'x' {
+					w.WriteByte(byte(r))
+				} else {
+					w.WriteRune(r)
+				}
+				i += n
+				continue
+			}
+			if e, ok := escapes[s[i]]; ok {
+				w.WriteByte(e)
+				continue
+			}
+			w.WriteByte('\\')
+			w.WriteByte(s[i])
+		default:
+			w.WriteByte(s[i])
+		}
+	}
+	return 0, errors.New("unterminated $' quote")
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func hexVal(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	}
+	return c - 'A' + 10
+}
@@ -0,0 +1,111 @@
+package imports
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+type har struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				Method   string `json:"method"`
+				URL      string `json:"url"`
+				Headers  []struct{ Name, Value string }
+				PostData *struct {
+					MimeType string `json:"mimeType"`
+					Text     string `json:"text"`
+					Params   []struct{ Name, Value string }
+				} `json:"postData"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// headers the browser adds that don't belong in a rest file
+var harSkipHeaders = []string{
+	"host", "content-length", "connection", "accept-encoding", "keep-alive",
+	"upgrade-insecure-requests", "priority",
+}
+
+func skipHARHeader(name string) bool {
+	name = strings.ToLower(name)
+	// http2 pseudo headers and fetch metadata
+	if strings.HasPrefix(name, ":") || strings.HasPrefix(name, "sec-") {
+		return true
+	}
+	for _, skip := range harSkipHeaders {
+		if name == skip {
+			return true
+		}
+	}
+	return false
+}
+
+// HAR converts every request in a har file (browser devtools export) into a
+// rest file, identical requests are only added once and when hosts are given
+// only requests to those hosts (or their subdomains) are kept
+func HAR(filename string, hosts ...string) (*File, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var h har
+	if err := json.Unmarshal(b, &h); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filename, err)
+	}
+
+	f := &File{}
+	seen := map[string]bool{}
+	for _, entry := range h.Log.Entries {
+		req := entry.Request
+		u, err := url.Parse(req.URL)
+		if err != nil || !matchHost(u.Hostname(), hosts) {
+			continue
+		}
+
+		headers := []Header{}
+		for _, hdr := range req.Headers {
+			if !skipHARHeader(hdr.Name) {
+				headers = append(headers, Header{Name: hdr.Name, Value: hdr.Value})
+			}
+		}
+		body := ""
+		if pd := req.PostData; pd != nil {
+			body = pd.Text
+			if body == "" && len(pd.Params) > 0 {
+				values := url.Values{}
+				for _, p := range pd.Params {
+					values.Add(p.Name, p.Value)
+				}
+				body = values.Encode()
+			}
+		}
+
+		key := req.Method + " " + req.URL + "\n" + body
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		f.Add(NewRequest(req.Method, req.URL, headers, body))
+	}
+	if len(f.Requests) == 0 {
+		return nil, fmt.Errorf("no requests in %s matched", filename)
+	}
+	return f, nil
+}
+
+func matchHost(host string, hosts []string) bool {
+	if len(hosts) == 0 {
+		return true
+	}
+	for _, h := range hosts {
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,237 @@
+package imports
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// dynamic variables from the jetbrains http client and vscode rest client
+var httpDynamic = map[string]string{
+	"$uuid":           "uuid()",
+	"$guid":           "uuid()",
+	"$random.uuid":    "uuid()",
+	"$timestamp":      "unix_now()",
+	"$isoTimestamp":   "timestamp()",
+	"$randomInt":      "random_int(0, 1000)",
+	"$random.integer": "random_int(0, 1000)",
+}
+
+var (
+	httpVarRe       = regexp.MustCompile(`^@([A-Za-z0-9_.-]+)\s*=\s*(.*)$`)
+	httpNameRe      = regexp.MustCompile(`^(?:#|//)\s*@name\s*[= ]\s*(\S+)`)
+	httpRequestRe   = regexp.MustCompile(`^(GET|POST|PUT|PATCH|DELETE|HEAD|OPTIONS|TRACE|CONNECT)\s+(\S+)(?:\s+HTTP/[0-9.]+)?$`)
+	httpHeaderRe    = regexp.MustCompile(`^([A-Za-z0-9!#$%&'*+.^_|~-]+)\s*:\s*(.*)$`)
+	httpNameCharsRe = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+	// interpolations, stripped to make labels
+	templateRe = regexp.MustCompile(`\$\{[^}]*\}`)
+)
+
+// HTTP converts a jetbrains/vscode .http file into a rest file, @vars become
+// locals and response handlers become after hook stubs
+func HTTP(filename string) (*File, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	f := &File{}
+	vars := newVariables(f, httpDynamic)
+
+	lines := strings.Split(strings.ReplaceAll(string(b), "\r\n", "\n"), "\n")
+	for _, section := range splitHTTPRequests(lines) {
+		r, err := httpRequest(vars, section)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		if r != nil {
+			f.Add(r)
+		}
+	}
+	vars.defineUndefined("not defined in the http file")
+	return f, nil
+}
+
+// splitHTTPRequests splits on ### separators, the title after ### is kept as
+// the first line of the section
+func splitHTTPRequests(lines []string) [][]string {
+	sections := [][]string{{}}
+	for _, line := range lines {
+		if title, ok := strings.CutPrefix(strings.TrimSpace(line), "###"); ok {
+			sections = append(sections, []string{"###" + title})
+			continue
+		}
+		sections[len(sections)-1] = append(sections[len(sections)-1], line)
+	}
+	return sections
+}
+
+func httpRequest(vars *variables, lines []string) (*Request, error) {
+	r := &Request{}
+	comments := []string{}
+	i := 0
+	// everything before the request line, variables, names and comments
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if title, ok := strings.CutPrefix(line, "###"); ok {
+			r.Label = strings.TrimSpace(title)
+			continue
+		}
+		if m := httpNameRe.FindStringSubmatch(line); m != nil {
+			// keep the ### title when @name was made from it
+			if httpName(r.Label) != m[1] {
+				r.Label = m[1]
+			}
+			continue
+		}
+		if m := httpVarRe.FindStringSubmatch(line); m != nil {
+			vars.f.SetLocal(Ident(m[1]), vars.template(strings.TrimSpace(m[2])), "")
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		if c, ok := strings.CutPrefix(line, "#"); ok {
+			comments = append(comments, strings.TrimSpace(c))
+			continue
+		}
+		if c, ok := strings.CutPrefix(line, "//"); ok {
+			comments = append(comments, strings.TrimSpace(c))
+			continue
+		}
+		break
+	}
+	if i == len(lines) {
+		// only variables or comments
+		return nil, nil
+	}
+
+	requestLine := strings.TrimSpace(lines[i])
+	m := httpRequestRe.FindStringSubmatch(requestLine)
+	if m != nil {
+		r.Method = m[1]
+		r.URL = m[2]
+	} else if fields := strings.Fields(requestLine); len(fields) > 0 {
+		// the method is optional and defaults to GET
+		r.Method = "GET"
+		r.URL = fields[0]
+	}
+	i++
+	// query strings can continue on the following indented lines
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(lines[i], " ") && !strings.HasPrefix(lines[i], "\t") ||
+			(!strings.HasPrefix(line, "?") && !strings.HasPrefix(line, "&")) {
+			break
+		}
+		r.URL += line
+	}
+	r.URL = vars.template(r.URL)
+	if r.Label == "" {
+		r.Label = NewRequest(r.Method, templateRe.ReplaceAllString(r.URL, ""), nil, "").Label
+	}
+	r.Comment = strings.Join(comments, "\n")
+
+	headers := []Header{}
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			i++
+			break
+		}
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		hm := httpHeaderRe.FindStringSubmatch(line)
+		if hm == nil {
+			return nil, fmt.Errorf("request %q: bad header %q", r.Label, line)
+		}
+		name, value := hm[1], strings.TrimSpace(hm[2])
+		if fields := strings.Fields(value); strings.EqualFold(name, "Authorization") &&
+			len(fields) == 3 && strings.EqualFold(fields[0], "Basic") {
+			// Basic user password
+			r.BasicAuth = vars.template(fields[1] + ":" + fields[2])
+			continue
+		}
+		headers = append(headers, Header{Name: name, Value: value})
+	}
+	isJSON := r.SetHeaders(headers, vars.template)
+
+	body := []string{}
+	handler := []string{}
+	inHandler := false
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case inHandler:
+			if before, ok := strings.CutSuffix(trimmed, "%}"); ok {
+				handler = append(handler, before)
+				inHandler = false
+				continue
+			}
+			handler = append(handler, line)
+		case strings.HasPrefix(trimmed, "> {%"):
+			rest := strings.TrimPrefix(trimmed, "> {%")
+			if before, ok := strings.CutSuffix(rest, "%}"); ok {
+				handler = append(handler, before)
+				continue
+			}
+			handler = append(handler, rest)
+			inHandler = true
+		case strings.HasPrefix(trimmed, "> "):
+			handler = append(handler, "handler file: "+strings.TrimSpace(trimmed[2:]))
+		case strings.HasPrefix(trimmed, "<> "):
+			// response reference from a previous run
+		default:
+			body = append(body, line)
+		}
+	}
+
+	if handler = trimLines(handler); len(handler) > 0 {
+		if strings.TrimSpace(handler[0]) == afterMarker {
+			// exported by rest, the lua is still there
+			lua := []string{}
+			for _, line := range handler[1:] {
+				lua = append(lua, strings.TrimPrefix(strings.TrimSpace(line), "// "))
+			}
+			r.After = strings.Join(lua, "\n")
+		} else {
+			r.After = "-- http client response handler, port to lua:\n-- " +
+				strings.Join(handler, "\n-- ")
+		}
+	}
+	bodyText := strings.TrimSpace(strings.Join(body, "\n"))
+	if path, ok := strings.CutPrefix(bodyText, "< "); ok && !strings.Contains(path, "\n") {
+		// body from a file
+		r.Body = fmt.Sprintf(`${read("%s")}`, Literal(strings.TrimSpace(path)))
+	} else if bodyText != "" {
+		var decoded any
+		if isJSON && json.Unmarshal([]byte(bodyText), &decoded) == nil {
+			r.Body = MapStrings(decoded, vars.template)
+		} else {
+			r.Body = vars.template(bodyText)
+		}
+	}
+	return r, nil
+}
+
+// first line of after hooks exported by rest, see the http export template
+// and the postman export
+const afterMarker = "// rest after hook (lua), port to javascript:"
+
+// httpName matches the @name the http export template makes from a label
+func httpName(label string) string {
+	return httpNameCharsRe.ReplaceAllString(label, "_")
+}
+
+func trimLines(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
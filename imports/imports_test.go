@@ -0,0 +1,326 @@
+package imports_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/taybart/rest"
+	"github.com/taybart/rest/exports/templates"
+	"github.com/taybart/rest/imports"
+	"github.com/taybart/rest/server"
+)
+
+// write renders the imported file and parses it back as a rest file
+func write(t *testing.T, f *imports.File) *rest.Rest {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "imported.rest")
+	out, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	if err := f.Write(out); err != nil {
+		t.Fatal(err)
+	}
+	restFile, err := rest.NewFile(filename)
+	if err != nil {
+		b, _ := os.ReadFile(filename)
+		t.Fatalf("%s\n%s", err, b)
+	}
+	return restFile
+}
+
+func TestOpenAPI(t *testing.T) {
+	t.Setenv("API_TOKEN", "secret")
+	f, err := imports.OpenAPI("./testdata/petstore.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	restFile := write(t, f)
+	if len(restFile.Requests) != 4 {
+		t.Fatal("expected 4 requests got", len(restFile.Requests))
+	}
+
+	list, err := restFile.Request("listPets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.URL != "https://api.petstore.example.com/v1/pets" {
+		t.Fatal("unexpected url", list.URL)
+	}
+	if list.BearerToken != "secret" || list.Query["limit"] != "10" {
+		t.Fatalf("expected auth and query from spec got %+v", list)
+	}
+
+	create, err := restFile.Request("createPet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"id":0,"name":"Fido ${not a template}","owner":{"email":"user@example.com","pets":[]},"tags":["string"]}`
+	if create.Body != expected {
+		t.Fatalf("expected body %s got %s", expected, create.Body)
+	}
+
+	get, err := restFile.Request("get_pets_petId")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if get.URL != "https://api.petstore.example.com/v1/pets/42" {
+		t.Fatal("unexpected url", get.URL)
+	}
+	if _, ok := get.Headers["X-API-Key"]; !ok {
+		t.Fatal("expected api key header")
+	}
+}
+
+func TestPostman(t *testing.T) {
+	f, err := imports.Postman("./testdata/collection.json", "./testdata/environment.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	restFile := write(t, f)
+	if len(restFile.Requests) != 3 {
+		t.Fatal("expected 3 requests got", len(restFile.Requests))
+	}
+
+	create, err := restFile.Request("users::create user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if create.URL != "http://localhost:8080/users" || create.BearerToken != "secret" {
+		t.Fatalf("expected url and auth from variables got %+v", create)
+	}
+	if create.Body != `{"admin":false,"name":"Ada"}` {
+		t.Fatal("unexpected body", create.Body)
+	}
+	if len(create.Headers["X-Trace"]) != 36 {
+		t.Fatal("expected $guid to become a uuid got", create.Headers["X-Trace"])
+	}
+	if create.After == "" {
+		t.Fatal("expected test script to be carried over")
+	}
+
+	login, err := restFile.Request("users::login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login.BasicAuth != "admin:hunter2" || login.Body != "remember=true" {
+		t.Fatalf("expected basic auth and form body got %+v", login)
+	}
+
+	health, err := restFile.Request("health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if health.BearerToken != "" {
+		t.Fatal("expected noauth to override collection auth")
+	}
+	if strings.TrimSpace(health.After) != "print(rest.res.status)" {
+		t.Fatal("expected after hook exported by rest to be restored got", health.After)
+	}
+}
+
+func TestCurl(t *testing.T) {
+	f, err := imports.Curl(`curl 'https://api.example.com/users?page=2' \
+  -X PUT -H 'Authorization: Bearer abc' -H 'Content-Type: application/json' \
+  -b 'sid=1; theme=dark' --data-raw $'{"name":"O\'Brien ${x}"}' --compressed -k`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restFile := write(t, f)
+	if !restFile.Parser.Config.InsecureNoVerifyTLS {
+		t.Fatal("expected -k to set insecure_no_verify_tls")
+	}
+	req, err := restFile.Request("put_users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != "PUT" || req.URL != "https://api.example.com/users?page=2" {
+		t.Fatalf("unexpected request %s %s", req.Method, req.URL)
+	}
+	if req.BearerToken != "abc" || req.Cookies["theme"] != "dark" {
+		t.Fatalf("expected auth and cookies got %+v", req)
+	}
+	if req.Body != `{"name":"O'Brien ${x}"}` {
+		t.Fatal("unexpected body", req.Body)
+	}
+
+	f, err = imports.Curl(`curl -F name=bob -u user:pass example.com/upload`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err = write(t, f).Request("post_upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.BasicAuth != "user:pass" || req.URL != "http://example.com/upload" {
+		t.Fatalf("unexpected request %+v", req)
+	}
+	expected := "--rest-form-boundary\r\nContent-Disposition: form-data; name=\"name\"\r\n\r\nbob\r\n--rest-form-boundary--\r\n"
+	if req.Body != expected {
+		t.Fatalf("expected multipart body got %q", req.Body)
+	}
+	// curl's jar isn't used as the cookie file since rest would rewrite it
+	f, err = imports.Curl(`curl -b cookies example.com`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Config["cookie_file"] != nil || !strings.Contains(f.Comment, "rest cookies import cookies") {
+		t.Fatalf("expected a comment about importing the jar got %q %v", f.Comment, f.Config)
+	}
+}
+
+func TestHAR(t *testing.T) {
+	f, err := imports.HAR("./testdata/devtools.har", "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	restFile := write(t, f)
+	// duplicate and other host requests are dropped
+	if len(restFile.Requests) != 2 {
+		t.Fatal("expected 2 requests got", len(restFile.Requests))
+	}
+	get, err := restFile.Request("get_users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if get.BasicAuth != "user:pass" || len(get.Headers) != 1 {
+		t.Fatalf("expected browser headers to be dropped got %+v", get.Headers)
+	}
+	post, err := restFile.Request("post_users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.Body != `{"name":"ada"}` {
+		t.Fatal("unexpected body", post.Body)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	t.Setenv("API_TOKEN", "secret")
+	f, err := imports.HTTP("./testdata/requests.http")
+	if err != nil {
+		t.Fatal(err)
+	}
+	restFile := write(t, f)
+	if len(restFile.Requests) != 2 {
+		t.Fatal("expected 2 requests got", len(restFile.Requests))
+	}
+
+	login, err := restFile.Request("login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login.URL != "http://localhost:8080/login" || login.BasicAuth != "ada:hunter2" {
+		t.Fatalf("expected url and auth from @vars got %+v", login)
+	}
+	if login.Body != `{"remember":true,"user":"ada"}` {
+		t.Fatal("unexpected body", login.Body)
+	}
+	if login.After == "" {
+		t.Fatal("expected response handler to become an after hook")
+	}
+
+	get, err := restFile.Request("get a user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if get.URL != "http://localhost:8080/users/1?fields=name&expand=true" {
+		t.Fatal("expected multiline query to be joined got", get.URL)
+	}
+	if get.BearerToken != "secret" || len(get.Headers["X-Request-ID"]) != 36 {
+		t.Fatalf("expected dynamic variables to be mapped got %+v", get)
+	}
+}
+
+func TestHTTPRoundTrip(t *testing.T) {
+	exported := filepath.Join(t.TempDir(), "api.http")
+	out, err := os.Create(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reqs := map[string]templates.Request{
+		"get": {Label: "get", Method: "GET", URL: "http://localhost:18080/users", BearerToken: "supersecrettoken"},
+	}
+	locals := map[string]string{"base": "http://localhost:18080", "token": "supersecrettoken"}
+	if err := templates.Get("http").Execute(out, "api.rest", reqs, locals); err != nil {
+		t.Fatal(err)
+	}
+	out.Close()
+
+	f, err := imports.HTTP(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Locals) != 2 || f.Requests[0].URL != "${locals.base}/users" || f.Requests[0].BearerToken != "${locals.token}" {
+		t.Fatalf("expected locals to survive the round trip got %+v %+v", f.Locals, f.Requests[0])
+	}
+}
+
+func TestRecordings(t *testing.T) {
+	dir := t.TempDir()
+	for _, rec := range []*server.Recording{
+		{Method: "GET", Path: "/health", Status: 200, Body: "ok ${not_a_template}",
+			Headers: http.Header{"Date": {"today"}, "X-Served-By": {"upstream"}}},
+		{Method: "POST", Path: "/orders", RequestBody: `{"sku": "a"}`, Status: 201, Body: `{"id": 1}`,
+			Headers: http.Header{"Content-Type": {"application/json"}}},
+		{Method: "POST", Path: "/orders", RequestBody: `sku=b`, Status: 400, Body: "bad (form)"},
+	} {
+		rec.BodyHash = hashBody(rec.RequestBody)
+		if err := rec.Save(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := imports.Recordings(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := write(t, f).Parser.Server()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Handlers) != 3 {
+		t.Fatal("expected 3 handlers got", len(config.Handlers))
+	}
+
+	ts := httptest.NewServer(server.New(config).Server.Handler)
+	defer ts.Close()
+	for _, tc := range []struct {
+		method, path, body string
+		status             int
+		expected           string
+	}{
+		{"GET", "/health", "", 200, "ok ${not_a_template}"},
+		{"POST", "/orders", `{"sku": "a"}`, 201, `{"id":1}`},
+		{"POST", "/orders", "sku=b", 400, "bad (form)"},
+	} {
+		req, _ := http.NewRequest(tc.method, ts.URL+tc.path, strings.NewReader(tc.body))
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(res.Body)
+		res.Body.Close()
+		if res.StatusCode != tc.status || string(body) != tc.expected {
+			t.Fatalf("%s %s %s: unexpected response %d %s", tc.method, tc.path, tc.body, res.StatusCode, body)
+		}
+		if tc.path == "/health" && (res.Header.Get("X-Served-By") != "upstream" || res.Header.Get("Date") == "today") {
+			t.Fatalf("unexpected headers %v", res.Header)
+		}
+	}
+}
+
+func hashBody(body string) string {
+	if body == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,215 @@
+package imports
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/taybart/rest/openapi"
+)
+
+// OpenAPI converts an openapi 3 or swagger 2 spec (json or yaml) into a rest
+// file with one request per operation
+func OpenAPI(filename string) (*File, error) {
+	spec, err := openapi.Load(filename)
+	if err != nil {
+		return nil, err
+	}
+	s := openAPI{spec}
+
+	f := &File{}
+	info := obj(spec.Doc["info"])
+	f.Comment = strings.TrimSpace(fmt.Sprintf("%s %s", str(info["title"]), str(info["version"])))
+	f.SetLocal("base_url", Literal(spec.BaseURL()), "")
+
+	for _, op := range spec.Operations() {
+		f.Add(s.request(f, op))
+	}
+	return f, nil
+}
+
+var pathParamRe = regexp.MustCompile(`\{([^}]+)\}`)
+
+type openAPI struct {
+	*openapi.Spec
+}
+
+func (s openAPI) request(f *File, operation openapi.Operation) *Request {
+	path, method, op := operation.Path, operation.Method, operation.Op
+	label := str(op["operationId"])
+	if label == "" {
+		label = Ident(method + pathParamRe.ReplaceAllString(path, "$1"))
+	}
+	r := &Request{
+		Label:  label,
+		Method: method,
+	}
+	for _, c := range []string{str(op["summary"]), str(op["description"])} {
+		if c != "" {
+			r.Comment = c
+			break
+		}
+	}
+
+	params := s.Parameters(operation)
+
+	r.URL = LocalRef("base_url") + pathParamRe.ReplaceAllStringFunc(Literal(path), func(m string) string {
+		name := m[1 : len(m)-1]
+		local := Ident(name)
+		example := "<" + name + ">"
+		if param, ok := params["path:"+name]; ok {
+			example = fmt.Sprint(s.paramExample(param))
+		}
+		f.SetLocal(local, Literal(example), "path parameter")
+		return LocalRef(local)
+	})
+
+	form := url.Values{}
+	for _, k := range openapi.SortedKeys(params) {
+		param := params[k]
+		name := str(param["name"])
+		example := Literal(fmt.Sprint(s.paramExample(param)))
+		switch str(param["in"]) {
+		case "query":
+			if r.Query == nil {
+				r.Query = map[string]string{}
+			}
+			r.Query[name] = example
+		case "header":
+			if r.Headers == nil {
+				r.Headers = map[string]string{}
+			}
+			r.Headers[name] = example
+		case "cookie":
+			if r.Cookies == nil {
+				r.Cookies = map[string]string{}
+			}
+			r.Cookies[name] = example
+		case "body":
+			setBody(r, "application/json", s.Example(param["schema"]))
+		case "formData":
+			form.Set(name, fmt.Sprint(s.paramExample(param)))
+		}
+	}
+	if len(form) > 0 {
+		setBody(r, "application/x-www-form-urlencoded", form.Encode())
+	}
+
+	if body := obj(s.Resolve(op["requestBody"])); body != nil {
+		content := obj(body["content"])
+		for _, ct := range openapi.SortedKeys(content) {
+			setBody(r, ct, s.MediaExample(obj(content[ct])))
+			// prefer json when there are multiple content types
+			if strings.Contains(ct, "json") {
+				break
+			}
+		}
+	}
+
+	s.auth(f, r, op)
+	return r
+}
+
+// setBody sets the content type and escapes body, json bodies are written as
+// hcl objects and form bodies are encoded
+func setBody(r *Request, contentType string, body any) {
+	if r.Headers == nil {
+		r.Headers = map[string]string{}
+	}
+	r.Headers["Content-Type"] = contentType
+	switch b := body.(type) {
+	case nil:
+		r.Body = nil
+	case string:
+		r.Body = Literal(b)
+	case map[string]any:
+		if strings.Contains(contentType, "json") {
+			r.Body = LiteralValue(b)
+			break
+		}
+		values := url.Values{}
+		for k, v := range b {
+			values.Set(k, fmt.Sprint(v))
+		}
+		r.Body = Literal(values.Encode())
+	default:
+		if strings.Contains(contentType, "json") {
+			r.Body = LiteralValue(b)
+		}
+	}
+}
+
+// auth maps the first security requirement to bearer_token, basic_auth or
+// an api key header/query param
+func (s openAPI) auth(f *File, r *Request, op map[string]any) {
+	security, ok := op["security"].([]any)
+	if !ok {
+		security = list(s.Doc["security"])
+	}
+	var schemes map[string]any
+	if s.Swagger {
+		schemes = obj(s.Doc["securityDefinitions"])
+	} else {
+		schemes = obj(obj(s.Doc["components"])["securitySchemes"])
+	}
+	for _, req := range security {
+		for _, name := range openapi.SortedKeys(obj(req)) {
+			scheme := obj(s.Resolve(schemes[name]))
+			switch typ := str(scheme["type"]); {
+			case typ == "basic" || (typ == "http" && strings.EqualFold(str(scheme["scheme"]), "basic")):
+				f.SetLocal("username", Expr(`env("API_USERNAME")`), "")
+				f.SetLocal("password", Expr(`env("API_PASSWORD")`), "")
+				r.BasicAuth = LocalRef("username") + ":" + LocalRef("password")
+			case typ == "http" || typ == "oauth2" || typ == "openIdConnect":
+				f.SetLocal("token", Expr(`env("API_TOKEN")`), "")
+				r.BearerToken = LocalRef("token")
+			case typ == "apiKey":
+				f.SetLocal("api_key", Expr(`env("API_KEY")`), "")
+				key := str(scheme["name"])
+				switch str(scheme["in"]) {
+				case "query":
+					if r.Query == nil {
+						r.Query = map[string]string{}
+					}
+					r.Query[key] = LocalRef("api_key")
+				case "cookie":
+					if r.Cookies == nil {
+						r.Cookies = map[string]string{}
+					}
+					r.Cookies[key] = LocalRef("api_key")
+				default:
+					if r.Headers == nil {
+						r.Headers = map[string]string{}
+					}
+					r.Headers[key] = LocalRef("api_key")
+				}
+			default:
+				continue
+			}
+			return
+		}
+	}
+}
+
+func (s openAPI) paramExample(param map[string]any) any {
+	if ex, ok := param["example"]; ok {
+		return ex
+	}
+	if examples := obj(param["examples"]); len(examples) > 0 {
+		return obj(s.Resolve(examples[openapi.SortedKeys(examples)[0]]))["value"]
+	}
+	if ex := s.Example(s.ParamSchema(param)); ex != nil && ex != "" {
+		if _, isObj := ex.(map[string]any); !isObj {
+			return ex
+		}
+	}
+	return "<" + str(param["name"]) + ">"
+}
+
+// short names for the spec helpers, they are used all over the importers
+var (
+	obj  = openapi.Obj
+	list = openapi.List
+	str  = openapi.Str
+)
@@ -0,0 +1,301 @@
+package imports
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/taybart/rest/exports/postman"
+)
+
+// postman dynamic variables that have an equivalent rest function
+var postmanDynamic = map[string]string{
+	"$guid":         "uuid()",
+	"$randomUUID":   "uuid()",
+	"$timestamp":    "unix_now()",
+	"$isoTimestamp": "timestamp()",
+	"$randomInt":    "random_int(0, 1000)",
+}
+
+type postmanEnvironment struct {
+	Name   string `json:"name"`
+	Values []struct {
+		Key     string `json:"key"`
+		Value   any    `json:"value"`
+		Enabled *bool  `json:"enabled"`
+	} `json:"values"`
+}
+
+type postmanImporter struct {
+	f *File
+	*variables
+}
+
+// Postman converts a v2.0/v2.1 postman collection into a rest file, folders
+// become label prefixes (folder/request) and variables from the collection
+// and any environment files become locals
+func Postman(collection string, environments ...string) (*File, error) {
+	r, err := os.Open(collection)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	c, err := postman.ParseCollection(r)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", collection, err)
+	}
+
+	f := &File{Comment: c.Info.Name}
+	p := &postmanImporter{f: f, variables: newVariables(f, postmanDynamic)}
+	for _, env := range environments {
+		if err := p.environment(env); err != nil {
+			return nil, err
+		}
+	}
+	p.collectionVariables(c.Variables)
+	p.items(c.Items, "", c.Auth)
+	p.defineUndefined("not defined in the collection")
+	return p.f, nil
+}
+
+func (p *postmanImporter) environment(filename string) error {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var env postmanEnvironment
+	if err := json.Unmarshal(b, &env); err != nil {
+		return fmt.Errorf("parsing %s: %w", filename, err)
+	}
+	comment := "environment: " + env.Name
+	for _, v := range env.Values {
+		if v.Enabled != nil && !*v.Enabled {
+			continue
+		}
+		p.f.SetLocal(Ident(v.Key), p.template(fmt.Sprint(v.Value)), comment)
+		comment = ""
+	}
+	return nil
+}
+
+func (p *postmanImporter) collectionVariables(vars []*postman.Variable) {
+	for _, v := range vars {
+		if v.Disabled {
+			continue
+		}
+		name := v.Key
+		if name == "" {
+			name = v.Name
+		}
+		p.f.SetLocal(Ident(name), p.template(v.Value), v.Description)
+	}
+}
+
+func (p *postmanImporter) items(items []*postman.Items, prefix string, auth *postman.Auth) {
+	for _, item := range items {
+		p.collectionVariables(item.Variables)
+		itemAuth := auth
+		if item.Auth != nil {
+			itemAuth = item.Auth
+		}
+		if item.IsGroup() {
+			// folders are namespaces like imports with namespace_imports
+			p.items(item.Items, prefix+item.Name+"::", itemAuth)
+			continue
+		}
+		if item.Request == nil {
+			continue
+		}
+		p.f.Add(p.request(prefix+item.Name, item, itemAuth))
+	}
+}
+
+func (p *postmanImporter) request(label string, item *postman.Items, auth *postman.Auth) *Request {
+	pr := item.Request
+	r := &Request{
+		Label:   label,
+		Comment: item.Description,
+		Method:  string(pr.Method),
+	}
+	if r.Method == "" {
+		r.Method = "GET"
+	}
+	if pr.URL != nil {
+		r.URL = p.template(pr.URL.Raw)
+	}
+	for _, h := range pr.Header {
+		if h.Disabled {
+			continue
+		}
+		if r.Headers == nil {
+			r.Headers = map[string]string{}
+		}
+		r.Headers[h.Key] = p.template(h.Value)
+	}
+	if pr.Auth != nil {
+		auth = pr.Auth
+	}
+	p.auth(r, auth)
+	p.body(r, pr.Body)
+
+	for _, e := range item.Events {
+		if e.Disabled || e.Script == nil || len(e.Script.Exec) == 0 {
+			continue
+		}
+		script := strings.Join(e.Script.Exec, "\n")
+		switch e.Listen {
+		case postman.PreRequest:
+			r.Comment = strings.TrimSpace(r.Comment + "\npre-request script:\n" + script)
+		case postman.Test:
+			r.After = postmanAfter(e.Script.Exec)
+		}
+	}
+	return r
+}
+
+// postmanAfter comments out a test script, lua exported by rest after the
+// marker line is restored
+func postmanAfter(exec []string) string {
+	script, lua := exec, []string{}
+	for i, line := range exec {
+		if strings.TrimSpace(line) == afterMarker {
+			script = exec[:i]
+			for _, l := range exec[i+1:] {
+				lua = append(lua, strings.TrimPrefix(strings.TrimPrefix(l, "//"), " "))
+			}
+			break
+		}
+	}
+	after := strings.Join(lua, "\n")
+	if script = trimLines(script); len(script) > 0 {
+		after = strings.TrimSpace("-- postman test script, port to lua:\n-- " +
+			strings.Join(script, "\n-- ") + "\n" + after)
+	}
+	return after
+}
+
+func authParam(auth *postman.Auth, key string) string {
+	for _, param := range auth.GetParams() {
+		if param.Key == key && param.Value != nil {
+			return fmt.Sprint(param.Value)
+		}
+	}
+	return ""
+}
+
+func (p *postmanImporter) auth(r *Request, auth *postman.Auth) {
+	if auth == nil {
+		return
+	}
+	switch auth.Type {
+	case postman.Basic:
+		r.BasicAuth = p.template(authParam(auth, "username")) + ":" + p.template(authParam(auth, "password"))
+	case postman.Bearer:
+		r.BearerToken = p.template(authParam(auth, "token"))
+	case postman.APIKey:
+		key, value := p.template(authParam(auth, "key")), p.template(authParam(auth, "value"))
+		if authParam(auth, "in") == "query" {
+			if r.Query == nil {
+				r.Query = map[string]string{}
+			}
+			r.Query[key] = value
+			return
+		}
+		if r.Headers == nil {
+			r.Headers = map[string]string{}
+		}
+		r.Headers[key] = value
+	case postman.NoAuth, "":
+	default:
+		r.Comment = strings.TrimSpace(fmt.Sprintf("%s\nTODO: %s auth is not supported", r.Comment, auth.Type))
+	}
+}
+
+type postmanField struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Type     string `json:"type"`
+	Src      any    `json:"src"`
+	Disabled bool   `json:"disabled"`
+}
+
+// fields decodes urlencoded/formdata since the model leaves them untyped
+func fields(v any) []postmanField {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	fields := []postmanField{}
+	json.Unmarshal(b, &fields)
+	return fields
+}
+
+func (p *postmanImporter) body(r *Request, body *postman.Body) {
+	if body == nil || body.Disabled {
+		return
+	}
+	setContentType := func(ct string) {
+		if r.Headers == nil {
+			r.Headers = map[string]string{}
+		}
+		for k := range r.Headers {
+			if strings.EqualFold(k, "Content-Type") {
+				return
+			}
+		}
+		r.Headers["Content-Type"] = ct
+	}
+	switch body.Mode {
+	case "raw":
+		if body.Raw == "" {
+			return
+		}
+		isJSON := body.Options != nil && body.Options.Raw.Language == postman.JSON
+		for k, v := range r.Headers {
+			if strings.EqualFold(k, "Content-Type") && strings.Contains(v, "json") {
+				isJSON = true
+			}
+		}
+		var decoded any
+		if isJSON && json.Unmarshal([]byte(body.Raw), &decoded) == nil {
+			setContentType("application/json")
+			r.Body = MapStrings(decoded, p.template)
+			return
+		}
+		r.Body = p.template(body.Raw)
+	case "urlencoded", "formdata":
+		values := map[string]any{}
+		for _, field := range fields(body.URLEncoded) {
+			if !field.Disabled {
+				values[field.Key] = p.template(field.Value)
+			}
+		}
+		for _, field := range fields(body.FormData) {
+			if field.Disabled {
+				continue
+			}
+			if field.Type == "file" {
+				r.Comment = strings.TrimSpace(fmt.Sprintf("%s\nTODO: form file %q (%v) was not imported", r.Comment, field.Key, field.Src))
+				continue
+			}
+			values[field.Key] = p.template(field.Value)
+		}
+		setContentType("application/x-www-form-urlencoded")
+		r.Body = Call("form", values)
+	case "graphql":
+		gql, ok := body.GraphQL.(map[string]any)
+		if !ok {
+			return
+		}
+		payload := map[string]any{"query": p.template(str(gql["query"]))}
+		if vars := str(gql["variables"]); vars != "" {
+			var decoded any
+			if json.Unmarshal([]byte(vars), &decoded) == nil {
+				payload["variables"] = MapStrings(decoded, p.template)
+			}
+		}
+		setContentType("application/json")
+		r.Body = payload
+	}
+}
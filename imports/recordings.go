@@ -0,0 +1,120 @@
+package imports
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/taybart/rest/server"
+)
+
+// response headers that are set by the server anyway
+var recordingSkipHeaders = []string{
+	"date", "content-length", "transfer-encoding", "connection", "keep-alive",
+}
+
+// Recordings converts the exchanges saved by proxy_record into handler
+// blocks, recordings on the same method and path get a match block on their
+// query and body so each one keeps being served for its own request
+func Recordings(dir string) (*File, error) {
+	recordings, err := server.LoadRecordings(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(recordings) == 0 {
+		return nil, fmt.Errorf("no recordings in %s", dir)
+	}
+
+	// the latest recording of a request wins like it does when replaying
+	latest := map[string]*server.Recording{}
+	routes := map[string]int{}
+	unique := []*server.Recording{}
+	for _, rec := range recordings {
+		if _, ok := latest[rec.Key()]; !ok {
+			unique = append(unique, rec)
+			routes[rec.Method+" "+rec.Path]++
+		}
+		latest[rec.Key()] = rec
+	}
+
+	srv := &Server{Address: "localhost:8080"}
+	for _, first := range unique {
+		rec := latest[first.Key()]
+		h := recordingHandler(rec)
+		if routes[rec.Method+" "+rec.Path] > 1 {
+			recordingMatch(h, rec)
+		}
+		srv.Handlers = append(srv.Handlers, h)
+	}
+	return &File{
+		Comment: "recorded from " + dir,
+		Server:  srv,
+	}, nil
+}
+
+func recordingHandler(rec *server.Recording) *Handler {
+	h := &Handler{
+		Method: rec.Method,
+		Path:   rec.Path,
+		Status: rec.Status,
+	}
+	isJSON := false
+	for name, values := range rec.Headers {
+		if skipRecordingHeader(name) {
+			continue
+		}
+		if strings.EqualFold(name, "Content-Type") {
+			isJSON = strings.Contains(strings.Join(values, ","), "json")
+		}
+		if h.Headers == nil {
+			h.Headers = map[string]string{}
+		}
+		h.Headers[name] = Literal(strings.Join(values, ", "))
+	}
+	switch {
+	case rec.Body == "":
+	case rec.BodyEncoding == "base64":
+		h.Comment = "TODO: binary response body was not converted"
+	default:
+		var decoded any
+		if isJSON && json.Unmarshal([]byte(rec.Body), &decoded) == nil {
+			h.Body = LiteralValue(decoded)
+		} else {
+			h.Body = Literal(rec.Body)
+		}
+	}
+	return h
+}
+
+// recordingMatch matches the query exactly and the body with body_json when it
+// is json or an anchored body_regex otherwise
+func recordingMatch(h *Handler, rec *server.Recording) {
+	if query, err := url.ParseQuery(rec.Query); err == nil && len(query) > 0 {
+		h.Query = map[string]string{}
+		for k := range query {
+			h.Query[k] = Literal(query.Get(k))
+		}
+	}
+	if rec.RequestBody == "" {
+		return
+	}
+	var decoded any
+	if err := json.Unmarshal([]byte(rec.RequestBody), &decoded); err == nil {
+		if _, ok := decoded.(map[string]any); ok {
+			h.BodyJSON = LiteralValue(decoded)
+			return
+		}
+	}
+	h.BodyRegex = Literal("^" + regexp.QuoteMeta(rec.RequestBody) + "$")
+}
+
+func skipRecordingHeader(name string) bool {
+	for _, skip := range recordingSkipHeaders {
+		if strings.EqualFold(name, skip) {
+			return true
+		}
+	}
+	return false
+}
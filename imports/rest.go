@@ -0,0 +1,460 @@
+// Package imports converts other request formats (openapi, postman, curl,
+// etc) into rest files, it is the opposite direction of the exports package
+package imports
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclwrite"
+)
+
+// Expr is written as a raw hcl expression, ex. env("TOKEN")
+type Expr string
+
+// Local is a single value in the locals block
+type Local struct {
+	Name string
+	// string, number, bool, Expr, map[string]any or []any
+	Value   any
+	Comment string
+}
+
+// Request is a request block, every string is written as an hcl template so
+// literal text from other formats must go through Literal first
+type Request struct {
+	Label   string
+	Comment string
+	Method  string
+	URL     string
+
+	BasicAuth   string
+	BearerToken string
+	Headers     map[string]string
+	Query       map[string]string
+	Cookies     map[string]string
+	// string for raw bodies or map[string]any/[]any for json bodies
+	Body any
+	// lua source for the after hook
+	After string
+	Skip  bool
+}
+
+// Handler is a handler block in the server block
+type Handler struct {
+	Comment string
+	Method  string
+	Path    string
+	// match block, empty when the handler is the fallback for its path
+	Query     map[string]string
+	BodyJSON  any
+	BodyRegex string
+	// response block, strings are templates like request attributes
+	Status  int
+	Headers map[string]string
+	Body    any
+}
+
+// Server is the server block
+type Server struct {
+	Address  string
+	Handlers []*Handler
+}
+
+// File is a rest file being built by an importer
+type File struct {
+	Comment string
+	// attributes for the config block
+	Config   map[string]any
+	Locals   []Local
+	Requests []*Request
+	Server   *Server
+	labels   map[string]int
+}
+
+// SetLocal adds a local if it isn't already set, the first value wins
+func (f *File) SetLocal(name string, value any, comment string) {
+	for _, l := range f.Locals {
+		if l.Name == name {
+			return
+		}
+	}
+	f.Locals = append(f.Locals, Local{Name: name, Value: value, Comment: comment})
+}
+
+// Add appends a request making sure its label is unique in the file
+func (f *File) Add(r *Request) {
+	if f.labels == nil {
+		f.labels = map[string]int{}
+	}
+	if r.Label == "" {
+		r.Label = "request"
+	}
+	f.labels[r.Label]++
+	if n := f.labels[r.Label]; n > 1 {
+		r.Label = fmt.Sprintf("%s_%d", r.Label, n)
+	}
+	f.Requests = append(f.Requests, r)
+}
+
+var identRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*$`)
+
+// Ident turns s into something usable as a local name
+func Ident(s string) string {
+	s = regexp.MustCompile(`[^a-zA-Z0-9_]+`).ReplaceAllString(s, "_")
+	s = strings.Trim(s, "_")
+	if s == "" {
+		return "value"
+	}
+	if s[0] >= '0' && s[0] <= '9' {
+		s = "_" + s
+	}
+	return s
+}
+
+// LocalRef returns a template reference to a local
+func LocalRef(name string) string {
+	return "${locals." + name + "}"
+}
+
+// Literal escapes s so it is not treated as a template
+func Literal(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '$', '%':
+			b.WriteByte(c)
+			if i+1 < len(s) && s[i+1] == '{' {
+				b.WriteByte(c)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// Write writes the file as formatted hcl
+func (f *File) Write(w io.Writer) error {
+	var b bytes.Buffer
+	if f.Comment != "" {
+		writeComment(&b, f.Comment, "")
+		b.WriteString("\n")
+	}
+	if len(f.Config) > 0 {
+		b.WriteString("config {\n")
+		for _, k := range slices.Sorted(maps.Keys(f.Config)) {
+			fmt.Fprintf(&b, "  %s = %s\n", k, value(f.Config[k], "  "))
+		}
+		b.WriteString("}\n\n")
+	}
+	if len(f.Locals) > 0 {
+		b.WriteString("locals {\n")
+		for _, l := range f.Locals {
+			if l.Comment != "" {
+				writeComment(&b, l.Comment, "  ")
+			}
+			fmt.Fprintf(&b, "  %s = %s\n", l.Name, value(l.Value, "  "))
+		}
+		b.WriteString("}\n\n")
+	}
+	for _, r := range f.Requests {
+		r.write(&b)
+		b.WriteString("\n")
+	}
+	if f.Server != nil {
+		f.Server.write(&b)
+	}
+	_, err := w.Write(hclwrite.Format(bytes.TrimRight(b.Bytes(), "\n")))
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+func (r *Request) write(b *bytes.Buffer) {
+	if r.Comment != "" {
+		writeComment(b, r.Comment, "")
+	}
+	fmt.Fprintf(b, "request %s {\n", quote(Literal(r.Label)))
+	attr := func(name, v string) {
+		if v != "" {
+			fmt.Fprintf(b, "  %s = %s\n", name, quote(v))
+		}
+	}
+	if r.Skip {
+		b.WriteString("  skip = true\n")
+	}
+	attr("method", strings.ToUpper(r.Method))
+	attr("url", r.URL)
+	attr("basic_auth", r.BasicAuth)
+	attr("bearer_token", r.BearerToken)
+	for _, m := range []struct {
+		name   string
+		values map[string]string
+	}{{"headers", r.Headers}, {"query", r.Query}, {"cookies", r.Cookies}} {
+		if len(m.values) == 0 {
+			continue
+		}
+		fmt.Fprintf(b, "  %s = {\n", m.name)
+		for _, k := range slices.Sorted(maps.Keys(m.values)) {
+			fmt.Fprintf(b, "    %s = %s\n", key(k), quote(m.values[k]))
+		}
+		b.WriteString("  }\n")
+	}
+	if r.Body != nil {
+		fmt.Fprintf(b, "  body = %s\n", value(r.Body, "  "))
+	}
+	if r.After != "" {
+		fmt.Fprintf(b, "  after = <<LUA\n%s\nLUA\n", heredoc(r.After, "    "))
+	}
+	b.WriteString("}\n")
+}
+
+func (s *Server) write(b *bytes.Buffer) {
+	b.WriteString("server {\n")
+	fmt.Fprintf(b, "  address = %s\n", quote(Literal(s.Address)))
+	for _, h := range s.Handlers {
+		b.WriteString("\n")
+		if h.Comment != "" {
+			writeComment(b, h.Comment, "  ")
+		}
+		fmt.Fprintf(b, "  handler %s %s {\n", quote(Literal(h.Method)), quote(Literal(h.Path)))
+		if len(h.Query) > 0 || h.BodyJSON != nil || h.BodyRegex != "" {
+			b.WriteString("    match {\n")
+			if len(h.Query) > 0 {
+				b.WriteString("      query = {\n")
+				for _, k := range slices.Sorted(maps.Keys(h.Query)) {
+					fmt.Fprintf(b, "        %s = %s\n", key(k), quote(h.Query[k]))
+				}
+				b.WriteString("      }\n")
+			}
+			if h.BodyJSON != nil {
+				fmt.Fprintf(b, "      body_json = %s\n", value(h.BodyJSON, "      "))
+			}
+			if h.BodyRegex != "" {
+				fmt.Fprintf(b, "      body_regex = %s\n", quote(h.BodyRegex))
+			}
+			b.WriteString("    }\n")
+		}
+		b.WriteString("    response {\n")
+		fmt.Fprintf(b, "      status = %d\n", h.Status)
+		if len(h.Headers) > 0 {
+			b.WriteString("      headers = {\n")
+			for _, k := range slices.Sorted(maps.Keys(h.Headers)) {
+				fmt.Fprintf(b, "        %s = %s\n", key(k), quote(h.Headers[k]))
+			}
+			b.WriteString("      }\n")
+		}
+		if h.Body != nil {
+			fmt.Fprintf(b, "      body = %s\n", value(h.Body, "      "))
+		}
+		b.WriteString("    }\n")
+		b.WriteString("  }\n")
+	}
+	b.WriteString("}\n")
+}
+
+func writeComment(b *bytes.Buffer, comment, indent string) {
+	for line := range strings.SplitSeq(strings.TrimSpace(comment), "\n") {
+		fmt.Fprintf(b, "%s# %s\n", indent, strings.TrimRight(line, " \t\r"))
+	}
+}
+
+func quote(template string) string {
+	return `"` + template + `"`
+}
+
+func key(k string) string {
+	if identRe.MatchString(k) && k != "null" && k != "true" && k != "false" {
+		return k
+	}
+	return quote(Literal(k))
+}
+
+// heredoc indents lua source and escapes template sequences
+func heredoc(src, indent string) string {
+	src = strings.NewReplacer("${", "$${", "%{", "%%{").Replace(src)
+	lines := strings.Split(strings.TrimRight(src, "\n"), "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = indent + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// value writes v as an hcl expression, strings are templates
+func value(v any, indent string) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case Expr:
+		return string(v)
+	case string:
+		return quote(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []any:
+		if len(v) == 0 {
+			return "[]"
+		}
+		var b strings.Builder
+		b.WriteString("[\n")
+		for _, e := range v {
+			fmt.Fprintf(&b, "%s  %s,\n", indent, value(e, indent+"  "))
+		}
+		b.WriteString(indent + "]")
+		return b.String()
+	case map[string]any:
+		if len(v) == 0 {
+			return "{}"
+		}
+		var b strings.Builder
+		b.WriteString("{\n")
+		for _, k := range slices.Sorted(maps.Keys(v)) {
+			fmt.Fprintf(&b, "%s  %s = %s\n", indent, key(k), value(v[k], indent+"  "))
+		}
+		b.WriteString(indent + "}")
+		return b.String()
+	}
+	return quote(Literal(fmt.Sprint(v)))
+}
+
+// LiteralValue escapes every string in a decoded json value
+func LiteralValue(v any) any {
+	return MapStrings(v, Literal)
+}
+
+// MapStrings calls fn on every string in a decoded json value
+func MapStrings(v any, fn func(string) string) any {
+	switch v := v.(type) {
+	case string:
+		return fn(v)
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = MapStrings(e, fn)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = MapStrings(e, fn)
+		}
+		return out
+	}
+	return v
+}
+
+// Call writes a function call expression with v as the only argument
+func Call(fn string, v any) Expr {
+	return Expr(fn + "(" + value(v, "  ") + ")")
+}
+
+// Header is a single header, order and duplicates are kept
+type Header struct {
+	Name  string
+	Value string
+}
+
+// NewRequest builds a request from raw http parts, auth and cookie headers are
+// pulled out into bearer_token/basic_auth/cookies and json bodies are decoded
+func NewRequest(method, rawURL string, headers []Header, body string) *Request {
+	r := &Request{
+		Method: strings.ToUpper(method),
+		URL:    Literal(rawURL),
+	}
+	if r.Method == "" {
+		r.Method = "GET"
+	}
+	label := strings.ToLower(r.Method)
+	if u, err := url.Parse(rawURL); err == nil {
+		label += u.Path
+	}
+	r.Label = Ident(label)
+
+	isJSON := r.SetHeaders(headers, Literal)
+	if body != "" {
+		var decoded any
+		if isJSON && json.Unmarshal([]byte(body), &decoded) == nil {
+			r.Body = LiteralValue(decoded)
+		} else {
+			r.Body = Literal(body)
+		}
+	}
+	return r
+}
+
+// SetHeaders adds headers to the request passing values through template,
+// auth and cookie headers are pulled out into bearer_token, basic_auth and
+// cookies. It reports whether the content type is json
+func (r *Request) SetHeaders(headers []Header, template func(string) string) bool {
+	isJSON := false
+	for _, h := range headers {
+		switch {
+		case strings.EqualFold(h.Name, "Authorization") && hasPrefixFold(h.Value, "Bearer "):
+			r.BearerToken = template(strings.TrimSpace(h.Value[len("Bearer "):]))
+			continue
+		case strings.EqualFold(h.Name, "Authorization") && hasPrefixFold(h.Value, "Basic "):
+			if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(h.Value[len("Basic "):])); err == nil {
+				r.BasicAuth = template(string(decoded))
+				continue
+			}
+		case strings.EqualFold(h.Name, "Cookie"):
+			for _, c := range strings.Split(h.Value, ";") {
+				name, value, _ := strings.Cut(strings.TrimSpace(c), "=")
+				if name == "" {
+					continue
+				}
+				if r.Cookies == nil {
+					r.Cookies = map[string]string{}
+				}
+				r.Cookies[name] = template(value)
+			}
+			continue
+		case strings.EqualFold(h.Name, "Content-Type"):
+			isJSON = strings.Contains(h.Value, "json")
+		}
+		if r.Headers == nil {
+			r.Headers = map[string]string{}
+		}
+		if prev, ok := r.Headers[h.Name]; ok {
+			r.Headers[h.Name] = prev + ", " + template(h.Value)
+			continue
+		}
+		r.Headers[h.Name] = template(h.Value)
+	}
+	return isJSON
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
@@ -0,0 +1,67 @@
+package imports
+
+import (
+	"regexp"
+	"strings"
+)
+
+// {{var}} style variables used by postman and .http files
+var mustacheRe = regexp.MustCompile(`\{\{\s*([^{}]+?)\s*\}\}`)
+
+// variables turns {{var}} references into locals and keeps track of the
+// ones that are never defined so they can be added as empty locals
+type variables struct {
+	f *File
+	// dynamic variables ($guid, $timestamp, etc) mapped to rest functions
+	dynamic   map[string]string
+	undefined []string
+}
+
+func newVariables(f *File, dynamic map[string]string) *variables {
+	return &variables{f: f, dynamic: dynamic}
+}
+
+// template escapes s and turns {{var}} into locals references
+func (v *variables) template(s string) string {
+	return mustacheRe.ReplaceAllStringFunc(Literal(s), func(m string) string {
+		name := mustacheRe.FindStringSubmatch(m)[1]
+		if fn, ok := v.dynamic[name]; ok {
+			return "${" + fn + "}"
+		}
+		// environment variables, $processEnv NAME (vscode) and $env.NAME (jetbrains)
+		for _, prefix := range []string{"$processEnv ", "$dotenv ", "$env."} {
+			if env, ok := strings.CutPrefix(name, prefix); ok {
+				return `${env("` + strings.TrimPrefix(strings.TrimSpace(env), "%") + `")}`
+			}
+		}
+		if strings.HasPrefix(name, "$") || strings.Contains(name, ".response.") {
+			// no equivalent, leave it for the user to fill in
+			return m
+		}
+		if !v.defined(Ident(name)) {
+			v.undefined = append(v.undefined, name)
+		}
+		return LocalRef(Ident(name))
+	})
+}
+
+func (v *variables) defined(local string) bool {
+	for _, l := range v.f.Locals {
+		if l.Name == local {
+			return true
+		}
+	}
+	return false
+}
+
+// defineUndefined adds an empty local for every variable that was used but
+// never defined
+func (v *variables) defineUndefined(comment string) {
+	for _, name := range v.undefined {
+		if !v.defined(Ident(name)) {
+			v.f.SetLocal(Ident(name), "", comment)
+			// only comment the first one
+			comment = ""
+		}
+	}
+}
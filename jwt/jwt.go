@@ -0,0 +1,247 @@
+// Package jwt signs, verifies and decodes json web tokens for rest files and the lua runtime
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+)
+
+var (
+	ErrMalformed        = errors.New("malformed jwt")
+	ErrInvalidSignature = errors.New("invalid jwt signature")
+	ErrUnsupportedAlg   = errors.New("unsupported jwt alg")
+	ErrAlgMismatch      = errors.New("jwt alg is not the expected alg")
+	ErrExpired          = errors.New("jwt is expired")
+	ErrNotValidYet      = errors.New("jwt is not valid yet")
+)
+
+var enc = base64.RawURLEncoding
+
+// Sign creates a token from claims, key is the shared secret for HS256 or
+// a pem encoded private key for RS256/ES256
+func Sign(claims map[string]any, key []byte, alg string) (string, error) {
+	if alg == "" {
+		alg = HS256
+	}
+	alg = strings.ToUpper(alg)
+	header, err := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(payload)
+	sig, err := sign(alg, []byte(unsigned), key)
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// Decode returns the header and claims of a token without checking the signature
+func Decode(token string) (map[string]any, map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, ErrMalformed
+	}
+	header := map[string]any{}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, nil, err
+	}
+	claims := map[string]any{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, nil, err
+	}
+	return header, claims, nil
+}
+
+// Verify checks the signature of a token along with the exp and nbf claims
+// if they are present, key is the same as Sign except RS256/ES256 take a pem
+// encoded public key or certificate. alg (HS256 when empty) is the algorithm
+// the caller expects, the token's header isn't trusted to pick it
+func Verify(token string, key []byte, alg string) (map[string]any, error) {
+	header, claims, err := Decode(token)
+	if err != nil {
+		return nil, err
+	}
+	if alg == "" {
+		alg = HS256
+	}
+	alg = strings.ToUpper(alg)
+	if tokenAlg, _ := header["alg"].(string); tokenAlg != alg {
+		return claims, fmt.Errorf("%w: expected %s got %q", ErrAlgMismatch, alg, tokenAlg)
+	}
+	idx := strings.LastIndex(token, ".")
+	sig, err := enc.DecodeString(token[idx+1:])
+	if err != nil {
+		return claims, ErrMalformed
+	}
+	if err := verify(alg, []byte(token[:idx]), sig, key); err != nil {
+		return claims, err
+	}
+
+	now := float64(time.Now().Unix())
+	if exp, ok := claims["exp"].(float64); ok && now >= exp {
+		return claims, ErrExpired
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now < nbf {
+		return claims, ErrNotValidYet
+	}
+	return claims, nil
+}
+
+func decodeSegment(seg string, to any) error {
+	b, err := enc.DecodeString(strings.TrimRight(seg, "="))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+	if err := json.Unmarshal(b, to); err != nil {
+		return fmt.Errorf("%w: %w", ErrMalformed, err)
+	}
+	return nil
+}
+
+func sign(alg string, data, key []byte) ([]byte, error) {
+	switch alg {
+	case HS256:
+		mac := hmac.New(sha256.New, key)
+		mac.Write(data)
+		return mac.Sum(nil), nil
+	case RS256:
+		priv, err := parsePrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		rsaKey, ok := priv.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("RS256 requires an rsa private key")
+		}
+		digest := sha256.Sum256(data)
+		return rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+	case ES256:
+		priv, err := parsePrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		ecKey, ok := priv.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("ES256 requires an ecdsa private key")
+		}
+		digest := sha256.Sum256(data)
+		r, s, err := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+		if err != nil {
+			return nil, err
+		}
+		// jws wants the fixed size r || s form instead of asn.1
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return sig, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlg, alg)
+}
+
+func verify(alg string, data, sig, key []byte) error {
+	switch alg {
+	case HS256:
+		// a public key as an hmac secret means the token could have been
+		// signed by anyone who has the key
+		if _, err := parsePublicKey(key); err == nil {
+			return errors.New("HS256 can't be verified with a public key")
+		}
+		expected, _ := sign(alg, data, key)
+		if !hmac.Equal(sig, expected) {
+			return ErrInvalidSignature
+		}
+		return nil
+	case RS256:
+		pub, err := parsePublicKey(key)
+		if err != nil {
+			return err
+		}
+		rsaKey, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("RS256 requires an rsa public key")
+		}
+		digest := sha256.Sum256(data)
+		if err := rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], sig); err != nil {
+			return ErrInvalidSignature
+		}
+		return nil
+	case ES256:
+		pub, err := parsePublicKey(key)
+		if err != nil {
+			return err
+		}
+		ecKey, ok := pub.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("ES256 requires an ecdsa public key")
+		}
+		if len(sig) != 64 {
+			return ErrInvalidSignature
+		}
+		digest := sha256.Sum256(data)
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(ecKey, digest[:], r, s) {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrUnsupportedAlg, alg)
+}
+
+func parsePrivateKey(key []byte) (any, error) {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return nil, errors.New("private key is not pem encoded")
+	}
+	if k, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	if k, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	return nil, errors.New("could not parse private key")
+}
+
+func parsePublicKey(key []byte) (any, error) {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return nil, errors.New("public key is not pem encoded")
+	}
+	if k, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	if k, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return k, nil
+	}
+	if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+		return cert.PublicKey, nil
+	}
+	return nil, errors.New("could not parse public key")
+}
@@ -0,0 +1,115 @@
+package jwt_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/taybart/rest/jwt"
+)
+
+func pemKeys(t *testing.T, priv any, pub any) ([]byte, []byte) {
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+}
+
+func TestRoundTrip(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPriv, rsaPub := pemKeys(t, rsaKey, &rsaKey.PublicKey)
+	ecPriv, ecPub := pemKeys(t, ecKey, &ecKey.PublicKey)
+
+	tests := []struct {
+		alg       string
+		signKey   []byte
+		verifyKey []byte
+	}{
+		{jwt.HS256, []byte("secret"), []byte("secret")},
+		{jwt.RS256, rsaPriv, rsaPub},
+		{jwt.ES256, ecPriv, ecPub},
+	}
+	for _, tt := range tests {
+		token, err := jwt.Sign(map[string]any{"sub": "user"}, tt.signKey, tt.alg)
+		if err != nil {
+			t.Fatal(tt.alg, err)
+		}
+		claims, err := jwt.Verify(token, tt.verifyKey, tt.alg)
+		if err != nil {
+			t.Fatal(tt.alg, err)
+		}
+		if claims["sub"] != "user" {
+			t.Fatal(tt.alg, "expected sub claim to be user got:", claims["sub"])
+		}
+		header, _, err := jwt.Decode(token)
+		if err != nil {
+			t.Fatal(tt.alg, err)
+		}
+		if header["alg"] != tt.alg {
+			t.Fatal("expected alg", tt.alg, "got:", header["alg"])
+		}
+	}
+}
+
+func TestVerifyFailures(t *testing.T) {
+	token, err := jwt.Sign(map[string]any{"sub": "user"}, []byte("secret"), jwt.HS256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Verify(token, []byte("wrong"), jwt.HS256); !errors.Is(err, jwt.ErrInvalidSignature) {
+		t.Fatal("expected invalid signature got:", err)
+	}
+
+	expired, err := jwt.Sign(map[string]any{
+		"exp": float64(time.Now().Add(-time.Minute).Unix()),
+	}, []byte("secret"), jwt.HS256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Verify(expired, []byte("secret"), ""); !errors.Is(err, jwt.ErrExpired) {
+		t.Fatal("expected expired got:", err)
+	}
+
+	if _, _, err := jwt.Decode("not.a-token"); !errors.Is(err, jwt.ErrMalformed) {
+		t.Fatal("expected malformed got:", err)
+	}
+}
+
+func TestAlgConfusion(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, rsaPub := pemKeys(t, rsaKey, &rsaKey.PublicKey)
+
+	// signed with the public key as an hmac secret
+	forged, err := jwt.Sign(map[string]any{"sub": "admin"}, rsaPub, jwt.HS256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Verify(forged, rsaPub, jwt.RS256); !errors.Is(err, jwt.ErrAlgMismatch) {
+		t.Fatal("expected alg mismatch got:", err)
+	}
+	if _, err := jwt.Verify(forged, rsaPub, jwt.HS256); err == nil {
+		t.Fatal("expected HS256 with a public key to fail")
+	}
+}
@@ -0,0 +1,143 @@
+package restlua
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"strings"
+
+	"github.com/taybart/rest/jwt"
+	lua "github.com/yuin/gopher-lua"
+)
+
+var hashes = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// pushDigest pushes the hex digest unless raw bytes were asked for
+func pushDigest(l *lua.LState, sum []byte, raw bool) int {
+	if raw {
+		l.Push(lua.LString(sum))
+		return 1
+	}
+	l.Push(lua.LString(hex.EncodeToString(sum)))
+	return 1
+}
+
+func hashFn(alg string) lua.LGFunction {
+	return func(l *lua.LState) int {
+		h := hashes[alg]()
+		h.Write([]byte(l.CheckString(1)))
+		return pushDigest(l, h.Sum(nil), l.OptBool(2, false))
+	}
+}
+
+// crypto.hmac(alg, key, msg, raw?)
+func luaHMAC(l *lua.LState) int {
+	alg := strings.ToLower(l.CheckString(1))
+	newHash, ok := hashes[alg]
+	if !ok {
+		l.ArgError(1, "unsupported hash "+alg)
+		return 0
+	}
+	mac := hmac.New(newHash, []byte(l.CheckString(2)))
+	mac.Write([]byte(l.CheckString(3)))
+	return pushDigest(l, mac.Sum(nil), l.OptBool(4, false))
+}
+
+func luaRandomBytes(l *lua.LState) int {
+	n := l.CheckInt(1)
+	if n < 0 {
+		l.ArgError(1, "n can't be negative")
+		return 0
+	}
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		l.RaiseError("%s", err.Error())
+		return 0
+	}
+	l.Push(lua.LString(b))
+	return 1
+}
+
+func luaHexEncode(l *lua.LState) int {
+	l.Push(lua.LString(hex.EncodeToString([]byte(l.CheckString(1)))))
+	return 1
+}
+
+func luaHexDecode(l *lua.LState) int {
+	b, err := hex.DecodeString(l.CheckString(1))
+	if err != nil {
+		l.Push(lua.LNil)
+		l.Push(lua.LString(err.Error()))
+		return 2
+	}
+	l.Push(lua.LString(b))
+	return 1
+}
+
+// jwt.decode(token) -> claims, header | nil, err
+func luaJWTDecode(l *lua.LState) int {
+	header, claims, err := jwt.Decode(l.CheckString(1))
+	if err != nil {
+		l.Push(lua.LNil)
+		l.Push(lua.LString(err.Error()))
+		return 2
+	}
+	l.Push(MapToLTable(l, claims))
+	l.Push(MapToLTable(l, header))
+	return 2
+}
+
+// jwt.verify(token, key, alg?) -> claims | nil, err
+func luaJWTVerify(l *lua.LState) int {
+	claims, err := jwt.Verify(l.CheckString(1), []byte(l.CheckString(2)), l.OptString(3, jwt.HS256))
+	if err != nil {
+		l.Push(lua.LNil)
+		l.Push(lua.LString(err.Error()))
+		return 2
+	}
+	l.Push(MapToLTable(l, claims))
+	return 1
+}
+
+// jwt.sign(claims, key, alg?) -> token | nil, err
+func luaJWTSign(l *lua.LState) int {
+	claims := LTableToMap(l.CheckTable(1))
+	token, err := jwt.Sign(claims, []byte(l.CheckString(2)), l.OptString(3, jwt.HS256))
+	if err != nil {
+		l.Push(lua.LNil)
+		l.Push(lua.LString(err.Error()))
+		return 2
+	}
+	l.Push(lua.LString(token))
+	return 1
+}
+
+var cryptoFns = map[string]lua.LGFunction{
+	"md5":          hashFn("md5"),
+	"sha1":         hashFn("sha1"),
+	"sha256":       hashFn("sha256"),
+	"sha512":       hashFn("sha512"),
+	"hmac":         luaHMAC,
+	"random_bytes": luaRandomBytes,
+}
+
+var hexFns = map[string]lua.LGFunction{
+	"encode": luaHexEncode,
+	"decode": luaHexDecode,
+}
+
+var jwtFns = map[string]lua.LGFunction{
+	"decode": luaJWTDecode,
+	"verify": luaJWTVerify,
+	"sign":   luaJWTSign,
+}
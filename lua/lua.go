@@ -45,6 +45,16 @@ func RegisterModules(l *lua.LState) error {
 			return err
 		}
 	}
+	// go backed modules, for things that are slow or easy to get wrong in lua
+	goLibs := map[string]map[string]lua.LGFunction{
+		"crypto": cryptoFns,
+		"hex":    hexFns,
+		"jwt":    jwtFns,
+		"time":   timeFns,
+	}
+	for name, fns := range goLibs {
+		l.SetGlobal(name, l.SetFuncs(l.NewTable(), fns))
+	}
 	// TODO: maybe add vim module that can somehow reach out to the plugin
 	//       this would be nice for copy to just add a vim.notify('success')
 
@@ -117,24 +127,7 @@ func MapToLTable(state *lua.LState, data map[string]any) *lua.LTable {
 	table := state.NewTable()
 
 	for key, value := range data {
-		var lval lua.LValue
-		switch v := value.(type) {
-		case string:
-			lval = lua.LString(v)
-		case float64:
-			lval = lua.LNumber(v)
-		case bool:
-			lval = lua.LBool(v)
-		case map[string]any:
-			// Recursively convert nested maps
-			lval = MapToLTable(state, v)
-		case nil:
-			lval = lua.LNil
-		default:
-			// Fallback for unknown types
-			lval = lua.LString(fmt.Sprint(v))
-		}
-
+		lval := ToLValue(state, value)
 		if i, err := strconv.Atoi(key); err == nil {
 			table.RawSetInt(i, lval)
 		} else {
@@ -145,6 +138,33 @@ func MapToLTable(state *lua.LState, data map[string]any) *lua.LTable {
 	return table
 }
 
+func ToLValue(state *lua.LState, value any) lua.LValue {
+	switch v := value.(type) {
+	case string:
+		return lua.LString(v)
+	case float64:
+		return lua.LNumber(v)
+	case int:
+		return lua.LNumber(v)
+	case bool:
+		return lua.LBool(v)
+	case map[string]any:
+		// Recursively convert nested maps
+		return MapToLTable(state, v)
+	case []any:
+		arr := state.NewTable()
+		for _, item := range v {
+			arr.Append(ToLValue(state, item))
+		}
+		return arr
+	case nil:
+		return lua.LNil
+	default:
+		// Fallback for unknown types
+		return lua.LString(fmt.Sprint(v))
+	}
+}
+
 func MakeLTableFromMap(l *lua.LState, inMap map[string]string) *lua.LTable {
 	tbl := l.NewTable()
 	for k, v := range inMap {
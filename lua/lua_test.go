@@ -0,0 +1,53 @@
+package restlua_test
+
+import (
+	"testing"
+
+	restlua "github.com/taybart/rest/lua"
+	lua "github.com/yuin/gopher-lua"
+)
+
+func run(t *testing.T, code string) lua.LValue {
+	l := lua.NewState()
+	defer l.Close()
+	if err := restlua.RegisterModules(l); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.DoString(code); err != nil {
+		t.Fatal(restlua.FmtError(code, err))
+	}
+	return l.Get(-1)
+}
+
+func TestCryptoModule(t *testing.T) {
+	tests := map[string]string{
+		`return crypto.sha256("abc")`:                "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		`return crypto.hmac("sha256", "key", "msg")`: "2d93cbc1be167bcb1637a4a23cbff01a7878f0c50ee833954ea5221bb1b8c628",
+		`return hex.encode(hex.decode("cafe"))`:      "cafe",
+		`return #crypto.random_bytes(16)`:            "16",
+		`return (pcall(crypto.random_bytes, -1))`:    "false",
+	}
+	for code, expected := range tests {
+		if got := run(t, code).String(); got != expected {
+			t.Fatalf("%s: expected %s got: %s", code, expected, got)
+		}
+	}
+}
+
+func TestTimeModule(t *testing.T) {
+	got := run(t, `return time.format(time.parse("2024-01-02T03:04:05Z"), "date")`).String()
+	if got != "2024-01-02" {
+		t.Fatal("expected 2024-01-02 got:", got)
+	}
+}
+
+func TestJWTModule(t *testing.T) {
+	got := run(t, `
+		local token = jwt.sign({ sub = "user" }, "secret")
+		local claims = jwt.verify(token, "secret")
+		return claims.sub
+	`).String()
+	if got != "user" {
+		t.Fatal("expected user got:", got)
+	}
+}
@@ -0,0 +1,92 @@
+package restlua
+
+import (
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// named layouts that can be passed instead of a go layout string
+var timeLayouts = map[string]string{
+	"rfc3339":     time.RFC3339,
+	"rfc3339nano": time.RFC3339Nano,
+	"rfc1123":     time.RFC1123,
+	"rfc1123z":    time.RFC1123Z,
+	"rfc822":      time.RFC822,
+	"kitchen":     time.Kitchen,
+	"datetime":    time.DateTime,
+	"date":        time.DateOnly,
+	"http":        "Mon, 02 Jan 2006 15:04:05 GMT",
+}
+
+func layout(l *lua.LState, n int) string {
+	name := l.OptString(n, "rfc3339")
+	if layout, ok := timeLayouts[strings.ToLower(name)]; ok {
+		return layout
+	}
+	return name
+}
+
+func toUnix(t time.Time) lua.LNumber {
+	return lua.LNumber(float64(t.UnixNano()) / float64(time.Second))
+}
+
+func fromUnix(ts float64) time.Time {
+	sec := int64(ts)
+	return time.Unix(sec, int64((ts-float64(sec))*float64(time.Second))).UTC()
+}
+
+// time.now() -> unix seconds, fractional
+func luaTimeNow(l *lua.LState) int {
+	l.Push(toUnix(time.Now()))
+	return 1
+}
+
+// time.unix() -> unix seconds, whole
+func luaTimeUnix(l *lua.LState) int {
+	l.Push(lua.LNumber(time.Now().Unix()))
+	return 1
+}
+
+// time.format(ts?, layout?) -> string
+func luaTimeFormat(l *lua.LState) int {
+	t := time.Now().UTC()
+	if l.GetTop() >= 1 && l.Get(1) != lua.LNil {
+		t = fromUnix(float64(l.CheckNumber(1)))
+	}
+	l.Push(lua.LString(t.Format(layout(l, 2))))
+	return 1
+}
+
+// time.parse(str, layout?) -> unix seconds | nil, err
+func luaTimeParse(l *lua.LState) int {
+	t, err := time.Parse(layout(l, 2), l.CheckString(1))
+	if err != nil {
+		l.Push(lua.LNil)
+		l.Push(lua.LString(err.Error()))
+		return 2
+	}
+	l.Push(toUnix(t))
+	return 1
+}
+
+// time.add(ts, "1h30m") -> unix seconds | nil, err
+func luaTimeAdd(l *lua.LState) int {
+	d, err := time.ParseDuration(l.CheckString(2))
+	if err != nil {
+		l.Push(lua.LNil)
+		l.Push(lua.LString(err.Error()))
+		return 2
+	}
+	l.Push(toUnix(fromUnix(float64(l.CheckNumber(1))).Add(d)))
+	return 1
+}
+
+var timeFns = map[string]lua.LGFunction{
+	"now":    luaTimeNow,
+	"unix":   luaTimeUnix,
+	"format": luaTimeFormat,
+	"parse":  luaTimeParse,
+	"add":    luaTimeAdd,
+}
@@ -0,0 +1,294 @@
+// Package openapi reads openapi 3 and swagger 2 specs (json or yaml), it
+// generates example values from schemas and validates values against them. It
+// is shared by the openapi importer and the mock server
+package openapi
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Methods are the operation keys of a path item in the order they are listed
+var Methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// maximum depth when following refs and generating example bodies
+const maxSchemaDepth = 8
+
+// Spec is a decoded spec, it is kept as plain maps so every version can be
+// read the same way
+type Spec struct {
+	Doc map[string]any
+	// swagger 2.0 instead of openapi 3
+	Swagger bool
+}
+
+// Operation is a method on a path
+type Operation struct {
+	Path   string
+	Method string
+	// the path item, for parameters shared by its operations
+	Item map[string]any
+	Op   map[string]any
+}
+
+// Load reads a spec from a json or yaml file
+func Load(filename string) (*Spec, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filename, err)
+	}
+	spec := &Spec{Doc: doc}
+	switch {
+	case Str(doc["openapi"]) != "":
+	case Str(doc["swagger"]) != "":
+		spec.Swagger = true
+	default:
+		return nil, fmt.Errorf("%s is not an openapi or swagger spec", filename)
+	}
+	return spec, nil
+}
+
+// Operations lists every operation sorted by path then method
+func (s *Spec) Operations() []Operation {
+	ops := []Operation{}
+	paths := Obj(s.Doc["paths"])
+	for _, path := range SortedKeys(paths) {
+		item := Obj(s.Resolve(paths[path]))
+		for _, method := range Methods {
+			op, ok := item[method].(map[string]any)
+			if !ok {
+				continue
+			}
+			ops = append(ops, Operation{Path: path, Method: method, Item: item, Op: op})
+		}
+	}
+	return ops
+}
+
+// Parameters returns the operation's parameters keyed by "in:name", path level
+// parameters apply to every operation but can be overridden
+func (s *Spec) Parameters(op Operation) map[string]map[string]any {
+	params := map[string]map[string]any{}
+	for _, p := range append(List(op.Item["parameters"]), List(op.Op["parameters"])...) {
+		param := Obj(s.Resolve(p))
+		params[Str(param["in"])+":"+Str(param["name"])] = param
+	}
+	return params
+}
+
+// ParamSchema is the schema of a parameter, swagger 2 puts it on the
+// parameter itself
+func (s *Spec) ParamSchema(param map[string]any) any {
+	schema := param["schema"]
+	if s.Swagger && schema == nil {
+		schema = param
+	}
+	return schema
+}
+
+// BaseURL is the first server's url with its variables filled in
+func (s *Spec) BaseURL() string {
+	if s.Swagger {
+		scheme := "https"
+		if schemes := List(s.Doc["schemes"]); len(schemes) > 0 {
+			scheme = Str(schemes[0])
+		}
+		host := Str(s.Doc["host"])
+		if host == "" {
+			host = "localhost"
+		}
+		return scheme + "://" + host + strings.TrimSuffix(Str(s.Doc["basePath"]), "/")
+	}
+	servers := List(s.Doc["servers"])
+	if len(servers) == 0 {
+		return "http://localhost"
+	}
+	server := Obj(servers[0])
+	u := Str(server["url"])
+	// fill in server variables with their defaults
+	vars := Obj(server["variables"])
+	for name, v := range vars {
+		u = strings.ReplaceAll(u, "{"+name+"}", Str(Obj(v)["default"]))
+	}
+	return strings.TrimSuffix(u, "/")
+}
+
+// Example generates a value from a schema, using its examples, default or
+// enum when it has them
+func (s *Spec) Example(schema any) any {
+	return s.example(schema, nil)
+}
+
+// example skips refs that are already being generated so recursive schemas stop
+func (s *Spec) example(schema any, seen []string) any {
+	if ref := Str(Obj(schema)["$ref"]); ref != "" {
+		if slices.Contains(seen, ref) || len(seen) > maxSchemaDepth {
+			return nil
+		}
+		seen = append(slices.Clip(seen), ref)
+	}
+	sch := Obj(s.Resolve(schema))
+	if sch == nil {
+		return nil
+	}
+	if ex, ok := sch["example"]; ok {
+		return ex
+	}
+	if ex := List(sch["examples"]); len(ex) > 0 {
+		return ex[0]
+	}
+	if def, ok := sch["default"]; ok {
+		return def
+	}
+	if enum := List(sch["enum"]); len(enum) > 0 {
+		return enum[0]
+	}
+	for _, k := range []string{"allOf", "oneOf", "anyOf"} {
+		options := List(sch[k])
+		if len(options) == 0 {
+			continue
+		}
+		if k != "allOf" {
+			return s.example(options[0], seen)
+		}
+		merged := map[string]any{}
+		for _, o := range options {
+			if m, ok := s.example(o, seen).(map[string]any); ok {
+				for k, v := range m {
+					merged[k] = v
+				}
+			}
+		}
+		return merged
+	}
+
+	switch schemaType(sch) {
+	case "object", "":
+		props := Obj(sch["properties"])
+		if props == nil && schemaType(sch) == "" {
+			if items, ok := sch["items"]; ok {
+				return []any{s.example(items, seen)}
+			}
+			return nil
+		}
+		out := map[string]any{}
+		for name, prop := range props {
+			out[name] = s.example(prop, seen)
+		}
+		return out
+	case "array":
+		item := s.example(sch["items"], seen)
+		if item == nil {
+			return []any{}
+		}
+		return []any{item}
+	case "integer":
+		return 0
+	case "number":
+		return 0.0
+	case "boolean":
+		return false
+	case "string":
+		switch Str(sch["format"]) {
+		case "date-time":
+			return "2024-01-01T00:00:00Z"
+		case "date":
+			return "2024-01-01"
+		case "email":
+			return "user@example.com"
+		case "uuid":
+			return "00000000-0000-0000-0000-000000000000"
+		case "uri", "url":
+			return "https://example.com"
+		case "binary", "byte":
+			return ""
+		}
+		return "string"
+	}
+	return nil
+}
+
+// MediaExample is the example of a media type (request or response content),
+// generated from its schema when it doesn't have one
+func (s *Spec) MediaExample(media map[string]any) any {
+	if ex, ok := media["example"]; ok {
+		return ex
+	}
+	if examples := Obj(media["examples"]); len(examples) > 0 {
+		return Obj(s.Resolve(examples[SortedKeys(examples)[0]]))["value"]
+	}
+	return s.Example(media["schema"])
+}
+
+// schemaType is the schema's type, openapi 3.1 allows a list of types in
+// which case the first that isn't null is used
+func schemaType(sch map[string]any) string {
+	types := List(sch["type"])
+	if len(types) == 0 {
+		return Str(sch["type"])
+	}
+	for _, typ := range types {
+		if Str(typ) != "null" {
+			return Str(typ)
+		}
+	}
+	return "null"
+}
+
+// Resolve follows local $refs (#/components/schemas/Name)
+func (s *Spec) Resolve(v any) any {
+	for range maxSchemaDepth {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return v
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#/") {
+			return v
+		}
+		var cur any = s.Doc
+		for part := range strings.SplitSeq(ref[2:], "/") {
+			part = strings.NewReplacer("~1", "/", "~0", "~").Replace(part)
+			cur = Obj(cur)[part]
+		}
+		v = cur
+	}
+	return v
+}
+
+func Obj(v any) map[string]any {
+	m, _ := v.(map[string]any)
+	return m
+}
+
+func List(v any) []any {
+	l, _ := v.([]any)
+	return l
+}
+
+func Str(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	return fmt.Sprint(v)
+}
+
+func SortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
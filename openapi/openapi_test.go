@@ -0,0 +1,87 @@
+package openapi_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/taybart/rest/openapi"
+)
+
+func load(t *testing.T, spec string) *openapi.Spec {
+	filename := filepath.Join(t.TempDir(), "spec.yaml")
+	if err := os.WriteFile(filename, []byte(spec), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := openapi.Load(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestValidate(t *testing.T) {
+	s := load(t, `
+openapi: 3.1.0
+components:
+  schemas:
+    Pet:
+      type: object
+      required: [name]
+      properties:
+        name:
+          type: [string, "null"]
+        kind:
+          enum: [cat, dog]
+        age:
+          oneOf:
+            - type: integer
+            - type: string
+              pattern: "^[0-9]+ years$"
+        tags:
+          type: array
+          maxItems: 1
+          items:
+            type: string
+            minLength: 2
+`)
+	pet := map[string]any{"$ref": "#/components/schemas/Pet"}
+	tests := []struct {
+		value any
+		errs  []string
+	}{
+		{map[string]any{"name": nil, "kind": "cat", "age": 2.0}, nil},
+		{map[string]any{"name": "Fido", "age": "3 years", "tags": []any{"ok"}}, nil},
+		{map[string]any{"kind": "bird"}, []string{"pet.name: is required", "pet.kind: bird is not one of [cat dog]"}},
+		{map[string]any{"name": 1.0, "age": "old"}, []string{
+			"pet.age: expected exactly one of the oneOf schemas to match, 0 did",
+			"pet.name: expected string got number",
+		}},
+		{map[string]any{"name": "a", "tags": []any{"x", "yy"}}, []string{
+			"pet.tags: expected at most 1 items got 2",
+			"pet.tags[0]: expected at least 2 characters got 1",
+		}},
+		{[]any{}, []string{"pet: expected object got array"}},
+	}
+	for _, test := range tests {
+		if errs := s.Validate(pet, test.value, "pet"); !slices.Equal(errs, test.errs) {
+			t.Fatalf("validating %v expected %q got %q", test.value, test.errs, errs)
+		}
+	}
+}
+
+func TestParseParam(t *testing.T) {
+	s := load(t, "openapi: 3.0.3\n")
+	ids := map[string]any{"type": "array", "items": map[string]any{"type": "integer"}}
+	if ids := s.ParseParam(ids, []string{"1,2"}); !slices.Equal(ids.([]any), []any{1.0, 2.0}) {
+		t.Fatal("expected comma separated ids got", ids)
+	}
+	if v := s.ParseParam(map[string]any{"type": "boolean"}, []string{"true"}); v != true {
+		t.Fatal("expected a boolean got", v)
+	}
+	// left as a string so validating it fails
+	if v := s.ParseParam(map[string]any{"type": "integer"}, []string{"abc"}); v != "abc" {
+		t.Fatal("expected the raw value got", v)
+	}
+}
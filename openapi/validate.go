@@ -0,0 +1,235 @@
+package openapi
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validate checks a decoded json value against a schema, every mismatch is
+// returned prefixed with where it is (ex. "body.owner.email: expected string")
+func (s *Spec) Validate(schema any, v any, at string) []string {
+	return s.validate(schema, v, at, 0)
+}
+
+func (s *Spec) validate(schema any, v any, at string, depth int) []string {
+	sch := Obj(s.Resolve(schema))
+	if sch == nil || depth > maxSchemaDepth*4 {
+		return nil
+	}
+	errs := []string{}
+	fail := func(format string, args ...any) {
+		errs = append(errs, at+": "+fmt.Sprintf(format, args...))
+	}
+
+	if v == nil {
+		if nullable(sch) {
+			return nil
+		}
+		if typ := schemaType(sch); typ != "" {
+			fail("expected %s got null", typ)
+		}
+		return errs
+	}
+
+	for _, o := range List(sch["allOf"]) {
+		errs = append(errs, s.validate(o, v, at, depth+1)...)
+	}
+	for _, k := range []string{"oneOf", "anyOf"} {
+		options := List(sch[k])
+		if len(options) == 0 {
+			continue
+		}
+		matched := 0
+		for _, o := range options {
+			if len(s.validate(o, v, at, depth+1)) == 0 {
+				matched++
+			}
+		}
+		switch {
+		case k == "oneOf" && matched != 1:
+			fail("expected exactly one of the oneOf schemas to match, %d did", matched)
+		case matched == 0:
+			fail("expected at least one of the anyOf schemas to match")
+		}
+	}
+	if enum := List(sch["enum"]); len(enum) > 0 && !slices.ContainsFunc(enum, func(e any) bool { return equal(e, v) }) {
+		fail("%v is not one of %v", v, enum)
+	}
+
+	switch typ := schemaType(sch); typ {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			fail("expected object got %s", typeName(v))
+			return errs
+		}
+		errs = append(errs, s.validateObject(sch, obj, at, depth)...)
+	case "", "any":
+		// no type, properties are still checked when it is an object
+		if obj, ok := v.(map[string]any); ok && (sch["properties"] != nil || sch["required"] != nil) {
+			errs = append(errs, s.validateObject(sch, obj, at, depth)...)
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			fail("expected array got %s", typeName(v))
+			return errs
+		}
+		if lo, ok := number(sch["minItems"]); ok && float64(len(arr)) < lo {
+			fail("expected at least %v items got %d", lo, len(arr))
+		}
+		if hi, ok := number(sch["maxItems"]); ok && float64(len(arr)) > hi {
+			fail("expected at most %v items got %d", hi, len(arr))
+		}
+		for i, item := range arr {
+			errs = append(errs, s.validate(sch["items"], item, fmt.Sprintf("%s[%d]", at, i), depth+1)...)
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			fail("expected string got %s", typeName(v))
+			return errs
+		}
+		n := float64(utf8.RuneCountInString(str))
+		if lo, ok := number(sch["minLength"]); ok && n < lo {
+			fail("expected at least %v characters got %v", lo, n)
+		}
+		if hi, ok := number(sch["maxLength"]); ok && n > hi {
+			fail("expected at most %v characters got %v", hi, n)
+		}
+		if pattern := Str(sch["pattern"]); pattern != "" {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(str) {
+				fail("%q does not match %s", str, pattern)
+			}
+		}
+	case "integer", "number":
+		n, ok := number(v)
+		if !ok {
+			fail("expected %s got %s", typ, typeName(v))
+			return errs
+		}
+		if typ == "integer" && n != math.Trunc(n) {
+			fail("expected integer got %v", n)
+		}
+		if lo, ok := number(sch["minimum"]); ok && n < lo {
+			fail("expected at least %v got %v", lo, n)
+		}
+		if hi, ok := number(sch["maximum"]); ok && n > hi {
+			fail("expected at most %v got %v", hi, n)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			fail("expected boolean got %s", typeName(v))
+		}
+	}
+	return errs
+}
+
+func (s *Spec) validateObject(sch map[string]any, obj map[string]any, at string, depth int) []string {
+	errs := []string{}
+	for _, name := range List(sch["required"]) {
+		if _, ok := obj[Str(name)]; !ok {
+			errs = append(errs, fmt.Sprintf("%s.%s: is required", at, Str(name)))
+		}
+	}
+	props := Obj(sch["properties"])
+	for _, name := range SortedKeys(obj) {
+		if prop, ok := props[name]; ok {
+			errs = append(errs, s.validate(prop, obj[name], at+"."+name, depth+1)...)
+			continue
+		}
+		switch extra := sch["additionalProperties"].(type) {
+		case bool:
+			if !extra {
+				errs = append(errs, fmt.Sprintf("%s.%s: is not allowed", at, name))
+			}
+		case map[string]any:
+			errs = append(errs, s.validate(extra, obj[name], at+"."+name, depth+1)...)
+		}
+	}
+	return errs
+}
+
+// ParseParam converts a path, query or header value into the type its schema
+// expects so it can be validated, arrays are taken from every value or a comma
+// separated one
+func (s *Spec) ParseParam(schema any, values []string) any {
+	sch := Obj(s.Resolve(schema))
+	if len(values) == 0 {
+		return nil
+	}
+	switch schemaType(sch) {
+	case "array":
+		if len(values) == 1 {
+			values = strings.Split(values[0], ",")
+		}
+		out := make([]any, len(values))
+		for i, v := range values {
+			out[i] = s.ParseParam(sch["items"], []string{v})
+		}
+		return out
+	case "integer", "number":
+		if n, err := strconv.ParseFloat(values[0], 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(values[0]); err == nil {
+			return b
+		}
+	}
+	return values[0]
+}
+
+func nullable(sch map[string]any) bool {
+	if b, ok := sch["nullable"].(bool); ok && b {
+		return true
+	}
+	// openapi 3.1 puts null in the type list
+	return slices.ContainsFunc(List(sch["type"]), func(t any) bool { return Str(t) == "null" })
+}
+
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func equal(a, b any) bool {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		return ok && x == y
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	if _, ok := number(v); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
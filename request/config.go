@@ -7,6 +7,8 @@ type Config struct {
 	InsecureNoVerifyTLS bool   `hcl:"insecure_no_verify_tls,optional"`
 	NamespaceImports    bool   `hcl:"namespace_imports,optional"`
 	SkipImported        bool   `hcl:"skip_imported,optional"`
+	History             bool   `hcl:"history,optional"`
+	CookieFile          string `hcl:"cookie_file,optional"`
 }
 
 func DefaultConfig() Config {
@@ -17,5 +19,6 @@ func DefaultConfig() Config {
 		InsecureNoVerifyTLS: false,
 		NamespaceImports:    true,
 		SkipImported:        false,
+		History:             false,
 	}
 }
@@ -39,6 +39,8 @@ type Request struct {
 	ExpectStatus int     `hcl:"expect,optional"`
 	Delay        string  `hcl:"delay,optional"`
 	Skip         bool    `hcl:"skip,optional"`
+	// expanded by the parser, each.key/each.value are available in the block
+	ForEach hcl.Expression `hcl:"for_each,optional"`
 
 	// ...rest
 	Remain hcl.Expression `hcl:"remain,optional"`
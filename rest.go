@@ -2,15 +2,19 @@
 package rest
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"sort"
 
 	"github.com/taybart/rest/client"
 	"github.com/taybart/rest/exports"
 	"github.com/taybart/rest/exports/templates"
 	"github.com/taybart/rest/file"
+	"github.com/taybart/rest/history"
 	"github.com/taybart/rest/request"
 	"github.com/taybart/rest/server"
 )
@@ -31,6 +35,10 @@ func NewFile(filename string) (*Rest, error) {
 		Parser:   parser,
 		Requests: make(map[string]*file.HCLRequest),
 	}
+	// cookie files live next to the rest file like history
+	if cf := parser.Config.CookieFile; cf != "" && !filepath.IsAbs(cf) {
+		parser.Config.CookieFile = filepath.Join(filepath.Dir(filename), cf)
+	}
 	for i, block := range parser.Root.Requests {
 		rest.Requests[block.Label] = block
 		rest.Requests[block.Label].BlockIndex = i
@@ -39,6 +47,19 @@ func NewFile(filename string) (*Rest, error) {
 
 }
 
+// NewClient creates a client for the file that records history when
+// history is set
+func (rest *Rest) NewClient() (*client.Client, error) {
+	c, err := client.New(rest.Parser.Config)
+	if err != nil {
+		return nil, err
+	}
+	if rest.Parser.Config.History {
+		c.History = history.Open(rest.filename)
+	}
+	return c, nil
+}
+
 func (rest *Rest) RequestByIndex(i int) (request.Request, error) {
 	var ret *file.HCLRequest
 	for _, r := range rest.Requests {
@@ -75,7 +96,7 @@ func (rest *Rest) RunFile(ignoreFail bool) error {
 		return rest.Requests[order[i]].BlockIndex < rest.Requests[order[j]].BlockIndex
 	})
 
-	client, err := client.New(rest.Parser.Config)
+	client, err := rest.NewClient()
 	if err != nil {
 		return err
 	}
@@ -98,7 +119,7 @@ func (rest *Rest) RunFile(ignoreFail bool) error {
 			}
 			fmt.Println(err)
 		}
-		rest.Parser.AddExportsCtx(exports)
+		rest.AddExports(label, exports)
 
 		if res != "" {
 			fmt.Println(res)
@@ -107,7 +128,47 @@ func (rest *Rest) RunFile(ignoreFail bool) error {
 	return nil
 }
 
+// AddExports adds exports from a request into the parser context, exports
+// from for_each instances are keyed as exports[label][key]
+func (rest *Rest) AddExports(label string, exports map[string]any) {
+	if hreq, ok := rest.Requests[label]; ok && hreq.Each != nil {
+		rest.Parser.AddInstanceExportsCtx(hreq.Each, exports)
+		return
+	}
+	rest.Parser.AddExportsCtx(exports)
+}
+
+// Instances returns the labels of the for_each instances of a request block
+// in order of appearance
+func (rest *Rest) Instances(label string) []string {
+	instances := []*file.HCLRequest{}
+	for _, hreq := range rest.Requests {
+		if hreq.Each != nil && hreq.Each.Label == label {
+			instances = append(instances, hreq)
+		}
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].BlockIndex < instances[j].BlockIndex
+	})
+	labels := make([]string, len(instances))
+	for i, hreq := range instances {
+		labels[i] = hreq.Label
+	}
+	return labels
+}
+
 func (rest *Rest) RunLabel(label string) error {
+	if _, ok := rest.Requests[label]; !ok {
+		// run every instance when given the label of a for_each block
+		if instances := rest.Instances(label); len(instances) > 0 {
+			for _, instance := range instances {
+				if err := rest.RunLabel(instance); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
 	req, err := rest.Request(label)
 	if err != nil {
 		return err
@@ -124,7 +185,7 @@ func (rest *Rest) RunIndex(block int) error {
 }
 
 func (rest *Rest) run(req request.Request) error {
-	client, err := client.New(rest.Parser.Config)
+	client, err := rest.NewClient()
 	if err != nil {
 		return err
 	}
@@ -140,6 +201,37 @@ func (rest *Rest) run(req request.Request) error {
 	return err
 }
 
+// RunLoad load tests a single request and prints a report, timeseries is
+// an optional csv/json file for per second stats
+func (rest *Rest) RunLoad(label string, block int, config client.LoadConfig, timeseries string) error {
+	var req request.Request
+	var err error
+	if label != "" {
+		req, err = rest.Request(label)
+	} else {
+		req, err = rest.RequestByIndex(block)
+	}
+	if err != nil {
+		return err
+	}
+
+	c, err := client.New(rest.Parser.Config)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	report, err := c.Load(ctx, req, config)
+	if err != nil {
+		return err
+	}
+	report.Print(os.Stdout)
+	if timeseries != "" {
+		return report.WriteTimeSeries(timeseries)
+	}
+	return nil
+}
+
 func (rest *Rest) RunSocket(socketArg string) error {
 	socket, err := rest.Parser.Socket()
 	if err != nil {
@@ -205,7 +297,7 @@ func (rest *Rest) Export(export, label string, block int) error {
 		if ua == request.DefaultConfig().UserAgent {
 			ua = ""
 		}
-		expect := templates.Expect{}
+		expect := templates.Expect{Status: req.ExpectStatus}
 		if req.Expect != nil {
 			expect = templates.Expect{
 				Status:  req.Expect.Status,
@@ -224,10 +316,13 @@ func (rest *Rest) Export(export, label string, block int) error {
 			Label:   req.Label,
 			Delay:   req.Delay,
 			Expect:  expect,
-			// BlockIndex: req.BlockIndex,
+			// auth is only added to headers when the request is built
+			BasicAuth:   req.BasicAuth,
+			BearerToken: req.BearerToken,
+			BlockIndex:  rest.Requests[lab].BlockIndex,
 			// config
 			UserAgent: ua,
 		}
 	}
-	return t.Execute(os.Stdout, rest.filename, treqs)
+	return t.Execute(os.Stdout, rest.filename, treqs, rest.Parser.StringLocals())
 }
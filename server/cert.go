@@ -0,0 +1,269 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/taybart/log"
+)
+
+// TLSAuto makes the server generate its certificate at startup
+const TLSAuto = "auto"
+
+const (
+	caValidFor   = 10 * 365 * 24 * time.Hour
+	certValidFor = 397 * 24 * time.Hour
+)
+
+// CA signs the certificates made for tls = "auto" and rest cert gen
+type CA struct {
+	Cert *x509.Certificate
+	Key  *ecdsa.PrivateKey
+}
+
+// NewCA generates a self-signed certificate authority
+func NewCA() (*CA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "rest development ca", Organization: []string{"rest"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(caValidFor),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &CA{Cert: cert, Key: key}, nil
+}
+
+// LoadCA reads a ca written by Write, name is the path without .crt/.key
+func LoadCA(name string) (*CA, error) {
+	pair, err := tls.LoadX509KeyPair(name+".crt", name+".key")
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	key, ok := pair.PrivateKey.(*ecdsa.PrivateKey)
+	if !ok || !cert.IsCA {
+		return nil, fmt.Errorf("%s.crt is not a ca made by rest", name)
+	}
+	return &CA{Cert: cert, Key: key}, nil
+}
+
+// LoadOrNewCA loads the ca at name or generates one and writes it there
+func LoadOrNewCA(name string) (*CA, bool, error) {
+	if _, err := os.Stat(name + ".crt"); err == nil {
+		ca, err := LoadCA(name)
+		return ca, false, err
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, false, err
+	}
+	ca, err := NewCA()
+	if err != nil {
+		return nil, false, err
+	}
+	return ca, true, ca.Write(name)
+}
+
+// CertPEM is the ca certificate clients need to trust
+func (ca *CA) CertPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Cert.Raw})
+}
+
+// Write saves the ca to name.crt and name.key
+func (ca *CA) Write(name string) error {
+	key, err := encodeKey(ca.Key)
+	if err != nil {
+		return err
+	}
+	return writePair(name, ca.CertPEM(), key)
+}
+
+// Issue makes a certificate for hosts (dns names or ips) signed by the ca, it
+// can be used by servers and by clients for mtls
+func (ca *CA) Issue(hosts []string) (certPEM, keyPEM []byte, err error) {
+	if len(hosts) == 0 {
+		return nil, nil, errors.New("a certificate needs at least one host")
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: hosts[0], Organization: []string{"rest"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(certValidFor),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, &key.PublicKey, ca.Key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if keyPEM, err = encodeKey(key); err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), keyPEM, nil
+}
+
+// WriteCert issues a certificate for hosts and saves it to name.crt and
+// name.key, the format -t/tls expects
+func (ca *CA) WriteCert(name string, hosts []string) error {
+	cert, key, err := ca.Issue(hosts)
+	if err != nil {
+		return err
+	}
+	return writePair(name, cert, key)
+}
+
+func writePair(name string, cert, key []byte) error {
+	if dir := filepath.Dir(name); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(name+".crt", cert, 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(name+".key", key, 0o600)
+}
+
+func encodeKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func serialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// tlsHosts are the names the generated certificate is valid for, localhost
+// and the listen address are always included
+func (c Config) tlsHosts() []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	add := func(host string) {
+		if host != "" && !slices.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+	host, _, err := net.SplitHostPort(c.Addr)
+	if err != nil {
+		host = c.Addr
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		// listening everywhere, the machine's name is the best guess
+		if name, err := os.Hostname(); err == nil {
+			add(name)
+		}
+	} else {
+		add(host)
+	}
+	for _, h := range c.TLSHosts {
+		add(h)
+	}
+	return hosts
+}
+
+// TLSConfig loads the certificate from tls.{crt,key} or generates one with
+// tls = "auto", with tls_client_ca clients have to present a certificate
+// signed by it
+func (s *Server) TLSConfig() (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if s.Config.TLS == TLSAuto {
+		var ca *CA
+		var err error
+		if s.Config.TLSCA != "" {
+			var created bool
+			ca, created, err = LoadOrNewCA(s.Config.TLSCA)
+			if err == nil && created && !s.Config.Quiet {
+				log.Infof("wrote the ca to %s.crt, trust it to skip certificate warnings\n", s.Config.TLSCA)
+			}
+		} else {
+			ca, err = NewCA()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("tls ca: %w", err)
+		}
+		hosts := s.Config.tlsHosts()
+		certPEM, keyPEM, err := ca.Issue(hosts)
+		if err != nil {
+			return nil, err
+		}
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+		if !s.Config.Quiet {
+			log.Infof("generated a certificate for %s\n", strings.Join(hosts, ", "))
+		}
+	} else {
+		cert, err := tls.LoadX509KeyPair(s.Config.TLS+".crt", s.Config.TLS+".key")
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if s.Config.TLSClientCA != "" {
+		b, err := os.ReadFile(s.Config.TLSClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("tls client ca: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("tls client ca: no certificates in %s", s.Config.TLSClientCA)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
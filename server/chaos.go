@@ -0,0 +1,200 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/taybart/log"
+)
+
+// default status for error_rate
+const defaultChaosStatus = http.StatusServiceUnavailable
+
+// Chaos makes responses misbehave on purpose, it can be set on the server and
+// on handlers where it replaces the server's
+type Chaos struct {
+	// delay before responding, a fixed duration ("500ms") or a range ("200ms..2s")
+	Latency string `json:"latency" hcl:"latency,optional"`
+	// fraction of requests answered with error_status
+	ErrorRate   float64 `json:"error_rate" hcl:"error_rate,optional"`
+	ErrorStatus int     `json:"error_status" hcl:"error_status,optional"`
+	// fraction of requests where the connection is closed without a response
+	DropConnectionRate float64 `json:"drop_connection_rate" hcl:"drop_connection_rate,optional"`
+	// limit the response body to this many bytes per second
+	SlowBodyBPS int `json:"slow_body_bps" hcl:"slow_body_bps,optional"`
+	// makes the misbehaving reproducible between runs
+	Seed *uint64 `json:"seed" hcl:"seed,optional"`
+
+	once sync.Once
+	mu   sync.Mutex
+	rng  *rand.Rand
+}
+
+// Validate checks the latency range and rates
+func (c *Chaos) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if _, _, err := c.latency(); err != nil {
+		return err
+	}
+	for name, rate := range map[string]float64{"error_rate": c.ErrorRate, "drop_connection_rate": c.DropConnectionRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("chaos %s must be between 0 and 1, got %v", name, rate)
+		}
+	}
+	if c.ErrorStatus != 0 && (c.ErrorStatus < 100 || c.ErrorStatus > 999) {
+		return fmt.Errorf("chaos error_status %d is not a valid status", c.ErrorStatus)
+	}
+	if c.SlowBodyBPS < 0 {
+		return errors.New("chaos slow_body_bps can't be negative")
+	}
+	return nil
+}
+
+// latency parses "200ms..2s" into its bounds, a single duration is both
+func (c *Chaos) latency() (time.Duration, time.Duration, error) {
+	if c.Latency == "" {
+		return 0, 0, nil
+	}
+	from, to, isRange := strings.Cut(c.Latency, "..")
+	lo, err := time.ParseDuration(strings.TrimSpace(from))
+	if err != nil {
+		return 0, 0, fmt.Errorf("chaos latency: %w", err)
+	}
+	if !isRange {
+		return lo, lo, nil
+	}
+	hi, err := time.ParseDuration(strings.TrimSpace(to))
+	if err != nil {
+		return 0, 0, fmt.Errorf("chaos latency: %w", err)
+	}
+	if hi < lo {
+		return 0, 0, fmt.Errorf("chaos latency %q ends before it starts", c.Latency)
+	}
+	return lo, hi, nil
+}
+
+// float64 and int64N share one source so a seed reproduces the whole sequence
+func (c *Chaos) float64() float64 {
+	c.init()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rng.Float64()
+}
+
+func (c *Chaos) int64N(n int64) int64 {
+	c.init()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rng.Int64N(n)
+}
+
+func (c *Chaos) init() {
+	c.once.Do(func() {
+		seed := rand.Uint64()
+		if c.Seed != nil {
+			seed = *c.Seed
+		}
+		c.rng = rand.New(rand.NewPCG(seed, seed))
+	})
+}
+
+// chaos wraps fn with the handler's chaos block, or the server's when the
+// handler doesn't have one
+func (s *Server) chaos(c *Chaos, fn http.HandlerFunc) http.HandlerFunc {
+	return s.chaosFn(c, fn, true)
+}
+
+// chaosFn is chaos for handlers that take over the connection (websockets)
+// which can't have their body slowed down
+func (s *Server) chaosFn(c *Chaos, fn http.HandlerFunc, slowBody bool) http.HandlerFunc {
+	if c == nil {
+		c = s.Config.Chaos
+	}
+	if c == nil {
+		return fn
+	}
+	lo, hi, err := c.latency()
+	if err != nil {
+		log.Error(err)
+		return fn
+	}
+	status := c.ErrorStatus
+	if status == 0 {
+		status = defaultChaosStatus
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if hi > 0 {
+			delay := lo
+			if hi > lo {
+				delay += time.Duration(c.int64N(int64(hi-lo) + 1))
+			}
+			// the delay doesn't count against the server's write timeout
+			http.NewResponseController(w).SetWriteDeadline(time.Now().Add(delay + httpTimeout))
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if c.DropConnectionRate > 0 && c.float64() < c.DropConnectionRate {
+			// closes the connection without writing anything
+			panic(http.ErrAbortHandler)
+		}
+		if c.ErrorRate > 0 && c.float64() < c.ErrorRate {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		if slowBody && c.SlowBodyBPS > 0 {
+			// a slow body can take longer than the server's write timeout
+			http.NewResponseController(w).SetWriteDeadline(time.Time{})
+			w = &slowWriter{ResponseWriter: w, bps: c.SlowBodyBPS}
+		}
+		fn(w, r)
+	}
+}
+
+// chaos writes are split into chunks sent every slowTick
+const slowTick = 100 * time.Millisecond
+
+// slowWriter trickles the body out at bps bytes per second
+type slowWriter struct {
+	http.ResponseWriter
+	bps int
+}
+
+func (w *slowWriter) Write(b []byte) (int, error) {
+	chunk := max(w.bps*int(slowTick)/int(time.Second), 1)
+	written := 0
+	for written < len(b) {
+		end := min(written+chunk, len(b))
+		n, err := w.ResponseWriter.Write(b[written:end])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		if f, ok := w.ResponseWriter.(http.Flusher); ok {
+			f.Flush()
+		}
+		if written < len(b) {
+			time.Sleep(slowTick)
+		}
+	}
+	return written, nil
+}
+
+func (w *slowWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *slowWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,317 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// default number of requests kept in the journal
+const defaultJournalSize = 1000
+
+// JournalEntry is a request received by the server
+type JournalEntry struct {
+	ID      int         `json:"id"`
+	Time    time.Time   `json:"time"`
+	Method  string      `json:"method"`
+	Path    string      `json:"path"`
+	Query   string      `json:"query,omitempty"`
+	Headers http.Header `json:"headers"`
+	Body    string      `json:"body,omitempty"`
+	// handler that responded, empty for built in routes and the default response
+	Handler string `json:"handler,omitempty"`
+	// 0 when the connection was dropped without a response
+	Status int `json:"status"`
+}
+
+// request rebuilds enough of the request to run match rules against it
+func (e *JournalEntry) request() *http.Request {
+	return &http.Request{
+		Method: e.Method,
+		URL:    &url.URL{Path: e.Path, RawQuery: e.Query},
+		Header: e.Headers,
+	}
+}
+
+// Journal is a bounded in memory log of requests, the oldest are dropped
+// once it is full
+type Journal struct {
+	mu      sync.Mutex
+	size    int
+	nextID  int
+	entries []*JournalEntry
+}
+
+func NewJournal(size int) *Journal {
+	if size <= 0 {
+		size = defaultJournalSize
+	}
+	return &Journal{size: size}
+}
+
+func (j *Journal) add(e *JournalEntry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.nextID++
+	e.ID = j.nextID
+	j.entries = append(j.entries, e)
+	if len(j.entries) > j.size {
+		j.entries = j.entries[len(j.entries)-j.size:]
+	}
+}
+
+// Entries returns a copy of the journal, oldest first
+func (j *Journal) Entries() []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entries := make([]JournalEntry, len(j.entries))
+	for i, e := range j.entries {
+		entries[i] = *e
+	}
+	return entries
+}
+
+func (j *Journal) Clear() {
+	j.mu.Lock()
+	j.entries = nil
+	j.mu.Unlock()
+}
+
+type journalKey struct{}
+
+// setJournalHandler records which handler responded to the request
+func setJournalHandler(r *http.Request, handler string) {
+	if e, ok := r.Context().Value(journalKey{}).(*JournalEntry); ok {
+		e.Handler = handler
+	}
+}
+
+// journalPaths are not recorded so reading the journal doesn't change it and
+// resetting between tests doesn't show up in it
+var journalPaths = map[string]bool{
+	"/__requests__": true, "/__verify__": true,
+	"/__scenarios__": true, "/__scenarios__/reset": true,
+}
+
+// record adds every request to the journal once it has been served
+func (j *Journal) record(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if journalPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := peekBody(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		e := &JournalEntry{
+			Time:    time.Now(),
+			Method:  r.Method,
+			Path:    r.URL.Path,
+			Query:   r.URL.RawQuery,
+			Headers: r.Header.Clone(),
+			Body:    string(body),
+		}
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			// dropped connections are kept with a status of 0
+			if p := recover(); p != nil {
+				j.add(e)
+				panic(p)
+			}
+		}()
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), journalKey{}, e)))
+		e.Status = sw.status
+		if e.Status == 0 {
+			e.Status = http.StatusOK
+		}
+		j.add(e)
+	})
+}
+
+// statusWriter keeps the response status, flushing and hijacking are passed
+// through for streams and websockets
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		w.status = http.StatusSwitchingProtocols
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("response writer can't be hijacked")
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// JournalFilter selects journal entries, empty fields match everything
+type JournalFilter struct {
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Handler string `json:"handler"`
+	// only requests after this time
+	Since time.Time `json:"since"`
+	// match rules against the query, headers and body
+	*Match
+}
+
+func (f JournalFilter) matches(e *JournalEntry) bool {
+	if f.Method != "" && f.Method != e.Method {
+		return false
+	}
+	if f.Path != "" && !valueMatches(f.Path, e.Path) {
+		return false
+	}
+	if f.Handler != "" && !valueMatches(f.Handler, e.Handler) {
+		return false
+	}
+	if !f.Since.IsZero() && !e.Time.After(f.Since) {
+		return false
+	}
+	return f.Match.matches(e.request(), []byte(e.Body))
+}
+
+// Find returns the entries that match filter
+func (j *Journal) Find(filter JournalFilter) []JournalEntry {
+	found := []JournalEntry{}
+	for _, e := range j.Entries() {
+		if filter.matches(&e) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+// HandleRequests lists the journal (GET) or clears it (DELETE), GET takes
+// method, path, handler, since (RFC 3339) and limit query params
+func (s *Server) HandleRequests() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodDelete:
+			s.journal.Clear()
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case http.MethodGet:
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		filter := JournalFilter{
+			Method:  q.Get("method"),
+			Path:    q.Get("path"),
+			Handler: q.Get("handler"),
+		}
+		if since := q.Get("since"); since != "" {
+			t, err := time.Parse(time.RFC3339Nano, since)
+			if err != nil {
+				http.Error(w, "since must be RFC 3339: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			filter.Since = t
+		}
+		entries := s.journal.Find(filter)
+		if limit, err := strconv.Atoi(q.Get("limit")); err == nil && limit >= 0 && limit < len(entries) {
+			// most recent
+			entries = entries[len(entries)-limit:]
+		}
+		writeJSON(w, http.StatusOK, entries)
+	}
+}
+
+// Verify is the body of POST /__verify__, without a count at least one
+// matching request is expected
+type Verify struct {
+	JournalFilter
+	Count   *int `json:"count"`
+	AtLeast *int `json:"at_least"`
+	AtMost  *int `json:"at_most"`
+}
+
+type verifyResult struct {
+	OK       bool           `json:"ok"`
+	Count    int            `json:"count"`
+	Expected string         `json:"expected"`
+	Requests []JournalEntry `json:"requests"`
+}
+
+// HandleVerify checks how many journal entries match, it responds 200 when
+// the count is as expected and 417 otherwise
+func (s *Server) HandleVerify() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var v Verify
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		found := s.journal.Find(v.JournalFilter)
+		res := verifyResult{OK: true, Count: len(found), Requests: found}
+		switch {
+		case v.Count != nil:
+			res.OK = len(found) == *v.Count
+			res.Expected = strconv.Itoa(*v.Count)
+		case v.AtLeast != nil || v.AtMost != nil:
+			expected := []string{}
+			if v.AtLeast != nil {
+				res.OK = len(found) >= *v.AtLeast
+				expected = append(expected, ">= "+strconv.Itoa(*v.AtLeast))
+			}
+			if v.AtMost != nil {
+				res.OK = res.OK && len(found) <= *v.AtMost
+				expected = append(expected, "<= "+strconv.Itoa(*v.AtMost))
+			}
+			res.Expected = strings.Join(expected, " and ")
+		default:
+			res.OK = len(found) > 0
+			res.Expected = ">= 1"
+		}
+		status := http.StatusOK
+		if !res.OK {
+			status = http.StatusExpectationFailed
+		}
+		writeJSON(w, status, res)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
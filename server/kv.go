@@ -0,0 +1,135 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	restlua "github.com/taybart/rest/lua"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// KV is the store behind the lua kv table, values are kept as plain go values
+// so they can be shared between handler states and saved as json
+type KV struct {
+	mu     sync.Mutex
+	values map[string]any
+	// written after every change when set
+	file string
+}
+
+// NewKV loads the store from file when it exists, an empty file name keeps
+// the store in memory
+func NewKV(file string) (*KV, error) {
+	kv := &KV{values: map[string]any{}, file: file}
+	if file == "" {
+		return kv, nil
+	}
+	b, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return kv, nil
+	}
+	if err != nil {
+		return kv, err
+	}
+	if len(b) == 0 {
+		return kv, nil
+	}
+	return kv, json.Unmarshal(b, &kv.values)
+}
+
+func (kv *KV) Get(key string) (any, bool) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	v, ok := kv.values[key]
+	return v, ok
+}
+
+// Set stores value under key, a nil value deletes the key
+func (kv *KV) Set(key string, value any) error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	if value == nil {
+		delete(kv.values, key)
+	} else {
+		kv.values[key] = value
+	}
+	return kv.save()
+}
+
+func (kv *KV) Clear() error {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	kv.values = map[string]any{}
+	return kv.save()
+}
+
+func (kv *KV) save() error {
+	if kv.file == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(kv.values, "", "  ")
+	if err != nil {
+		return err
+	}
+	// written next to the file and renamed over it so a crash mid-write
+	// doesn't leave half a json file behind
+	f, err := os.CreateTemp(filepath.Dir(kv.file), filepath.Base(kv.file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), kv.file)
+}
+
+// luaTable exposes the store to a handler as kv.get(key) and kv.set(key, value)
+func (kv *KV) luaTable(l *lua.LState) *lua.LTable {
+	get := func(l *lua.LState) int {
+		v, ok := kv.Get(l.ToString(1))
+		if !ok {
+			l.Push(lua.LNil)
+			return 1
+		}
+		l.Push(restlua.ToLValue(l, v))
+		return 1
+	}
+	set := func(l *lua.LState) int {
+		if err := kv.Set(l.ToString(1), fromLValue(l.Get(2))); err != nil {
+			l.RaiseError("kv: %v", err)
+		}
+		return 0
+	}
+	return restlua.MakeLTable(l, map[string]lua.LValue{
+		"get": l.NewFunction(get),
+		"set": l.NewFunction(set),
+	})
+}
+
+// fromLValue copies a lua value out of the state it belongs to
+func fromLValue(v lua.LValue) any {
+	switch v := v.(type) {
+	case lua.LString:
+		return string(v)
+	case lua.LNumber:
+		return float64(v)
+	case lua.LBool:
+		return bool(v)
+	case *lua.LTable:
+		return restlua.LTableToMap(v)
+	case *lua.LNilType:
+		return nil
+	}
+	return lua.LVAsString(v)
+}
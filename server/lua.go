@@ -58,56 +58,56 @@ func execute(l *lua.LState, code string) error {
 	return cleanError
 }
 
-// this is goofy and probably not necessary
-var kv map[string]lua.LValue
+func (s *Server) luaHelpers(l *lua.LState, req *http.Request) error {
+	l.SetGlobal("kv", s.kv.luaTable(l))
 
-func kvCacheLoader(l *lua.LState) int {
-	if len(kv) == 0 {
-		kv = make(map[string]lua.LValue)
+	pathValue := func(l *lua.LState) int {
+		id := l.ToString(1) /* get argument */
+		v := req.PathValue(id)
+		l.Push(lua.LString(v))
+		return 1 /* number of results */
 	}
-	get := func(l *lua.LState) int {
-		key := l.ToString(1)
-		if v := kv[key]; v != nil {
-			l.Push(v)
+	state := func(l *lua.LState) int {
+		if state, ok := s.scenarios.state(l.CheckString(1)); ok {
+			l.Push(lua.LString(state))
 		} else {
 			l.Push(lua.LNil)
 		}
 		return 1
 	}
-	set := func(l *lua.LState) int {
-		key := l.ToString(1)
-		value := l.Get(2)
-		kv[key] = value
+	setState := func(l *lua.LState) int {
+		if err := s.scenarios.set(l.CheckString(1), l.CheckString(2)); err != nil {
+			l.RaiseError("%v", err)
+		}
 		return 0
 	}
-	l.SetGlobal("kv", restlua.MakeLTable(l, map[string]lua.LValue{
-		"get": l.NewFunction(get),
-		"set": l.NewFunction(set),
-	}))
-	// mod := l.SetFuncs(l.NewTable(), map[string]lua.LGFunction{
-	// 	"get": get,
-	// 	"set": set,
-	// })
-	// l.Push(mod)
-	return 1
-}
-
-func (s *Server) luaHelpers(l *lua.LState, req *http.Request) error {
-	kvCacheLoader(l)
-	// l.PreloadModule("kv", kvCacheLoader)
-
-	pathValue := func(l *lua.LState) int {
-		id := l.ToString(1) /* get argument */
-		v := req.PathValue(id)
-		l.Push(lua.LString(v))
-		return 1 /* number of results */
-	}
 	l.SetGlobal("s", restlua.MakeLTable(l, map[string]lua.LValue{
 		"path_value": l.NewFunction(pathValue),
+		"state":      l.NewFunction(state),
+		"set_state":  l.NewFunction(setState),
 	}))
 	return nil
 }
 
+// luaState is a lua runtime with the modules, rest global and server helpers
+// for the request
+func (s *Server) luaState(req *http.Request) (*lua.LState, error) {
+	l := lua.NewState()
+	if err := restlua.RegisterModules(l); err != nil {
+		l.Close()
+		return nil, err
+	}
+	if err := populateGlobalObject(l, req); err != nil {
+		l.Close()
+		return nil, err
+	}
+	if err := s.luaHelpers(l, req); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
 func (s *Server) RunLuaHandler(handler string, req *http.Request, w http.ResponseWriter) (Response, error) {
 
 	l := lua.NewState()
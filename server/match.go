@@ -0,0 +1,201 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/taybart/log"
+)
+
+// Match selects between handlers registered on the same path, values are
+// matched exactly unless they are "*" (present with any value) or wrapped in
+// slashes (/regex/)
+type Match struct {
+	Query       map[string]string `json:"query" hcl:"query,optional"`
+	Headers     map[string]string `json:"headers" hcl:"headers,optional"`
+	BodyJSON    json.RawMessage   `json:"body_json"`
+	BodyJSONHCL hcl.Expression    `hcl:"body_json,optional"`
+	BodyRegex   string            `json:"body_regex" hcl:"body_regex,optional"`
+	// handlers with a higher priority are tried first
+	Priority int `json:"priority" hcl:"priority,optional"`
+}
+
+// conditions is used to try more specific handlers first
+func (m *Match) conditions() int {
+	if m == nil {
+		return 0
+	}
+	n := len(m.Query) + len(m.Headers)
+	if len(m.BodyJSON) > 0 {
+		n++
+	}
+	if m.BodyRegex != "" {
+		n++
+	}
+	return n
+}
+
+func (m *Match) priority() int {
+	if m == nil {
+		return 0
+	}
+	return m.Priority
+}
+
+func (m *Match) needsBody() bool {
+	return m != nil && (len(m.BodyJSON) > 0 || m.BodyRegex != "")
+}
+
+func (m *Match) matches(r *http.Request, body []byte) bool {
+	if m == nil {
+		return true
+	}
+	query := r.URL.Query()
+	for k, want := range m.Query {
+		if !query.Has(k) || !anyValueMatches(want, query[k]) {
+			return false
+		}
+	}
+	for k, want := range m.Headers {
+		if !anyValueMatches(want, r.Header.Values(k)) {
+			return false
+		}
+	}
+	if len(m.BodyJSON) > 0 {
+		var want, got any
+		if err := json.Unmarshal(m.BodyJSON, &want); err != nil {
+			log.Errorf("invalid body_json: %v\n", err)
+			return false
+		}
+		if err := json.Unmarshal(body, &got); err != nil || !jsonContains(want, got) {
+			return false
+		}
+	}
+	if m.BodyRegex != "" {
+		re, err := compile(m.BodyRegex)
+		if err != nil || !re.Match(body) {
+			return false
+		}
+	}
+	return true
+}
+
+func anyValueMatches(want string, values []string) bool {
+	if len(values) == 0 {
+		return false
+	}
+	for _, v := range values {
+		if valueMatches(want, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func valueMatches(want, got string) bool {
+	if want == "*" {
+		return true
+	}
+	if len(want) > 1 && strings.HasPrefix(want, "/") && strings.HasSuffix(want, "/") {
+		re, err := compile(want[1 : len(want)-1])
+		return err == nil && re.MatchString(got)
+	}
+	return want == got
+}
+
+// jsonContains reports whether every field in want is in got, strings are
+// compared with valueMatches and arrays must match element by element
+func jsonContains(want, got any) bool {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			return false
+		}
+		for k, v := range w {
+			gv, ok := g[k]
+			if !ok || !jsonContains(v, gv) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		g, ok := got.([]any)
+		if !ok || len(g) != len(w) {
+			return false
+		}
+		for i := range w {
+			if !jsonContains(w[i], g[i]) {
+				return false
+			}
+		}
+		return true
+	case string:
+		g, ok := got.(string)
+		return ok && valueMatches(w, g)
+	}
+	return want == got
+}
+
+var regexps sync.Map
+
+func compile(expr string) (*regexp.Regexp, error) {
+	if re, ok := regexps.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		log.Errorf("invalid match regex %q: %v\n", expr, err)
+		return nil, err
+	}
+	regexps.Store(expr, re)
+	return re, nil
+}
+
+// sortHandlers orders handlers on the same path by priority, then by how
+// specific their match and scenario state are, keeping file order otherwise
+func sortHandlers(handlers []*Handler) []*Handler {
+	sorted := slices.Clone(handlers)
+	slices.SortStableFunc(sorted, func(a, b *Handler) int {
+		if a.Match.priority() != b.Match.priority() {
+			return b.Match.priority() - a.Match.priority()
+		}
+		return b.conditions() - a.conditions()
+	})
+	return sorted
+}
+
+// conditions counts the match block and a required scenario state
+func (h *Handler) conditions() int {
+	n := h.Match.conditions()
+	if h.RequiredState != "" {
+		n++
+	}
+	return n
+}
+
+// matches checks the method and match block of a handler
+func (h *Handler) matches(r *http.Request, body []byte) bool {
+	if h.Method != r.Method && h.Method != "*" {
+		return false
+	}
+	return h.Match.matches(r, body)
+}
+
+// peekBody reads the request body and puts it back for the handler
+func peekBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, err
+}
@@ -0,0 +1,303 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/taybart/log"
+	"github.com/taybart/rest/openapi"
+)
+
+// operation is a handler generated from an openapi operation, it validates
+// the request and responds with the documented status and example body
+type operation struct {
+	spec     *openapi.Spec
+	params   map[string]map[string]any
+	body     map[string]any
+	response Response
+	// spec path parameter names to wildcard names when the operation is on a
+	// handler block's path
+	wildcards map[string]string
+}
+
+// openAPIHandlers makes a handler for every operation in the spec, paths are
+// prefixed with the path of the spec's server url
+func (s *Server) openAPIHandlers() ([]*Handler, error) {
+	spec, err := openapi.Load(s.Config.OpenAPI)
+	if err != nil {
+		return nil, err
+	}
+	prefix := ""
+	if u, err := url.Parse(spec.BaseURL()); err == nil {
+		prefix = strings.TrimSuffix(u.Path, "/")
+	}
+	handlers := []*Handler{}
+	for _, op := range spec.Operations() {
+		path := prefix + op.Path
+		if !validPattern(path) {
+			log.Warnf("skipping openapi operation %s %s, wildcards must be a whole path segment\n", strings.ToUpper(op.Method), path)
+			continue
+		}
+		handlers = append(handlers, &Handler{
+			Method:    strings.ToUpper(op.Method),
+			Path:      path,
+			operation: newOperation(spec, op),
+		})
+	}
+	return handlers, nil
+}
+
+var wildcardRe = regexp.MustCompile(`\{[^}]*\}`)
+
+// validPattern checks that the path can be registered on a ServeMux
+func validPattern(path string) bool {
+	for segment := range strings.SplitSeq(path, "/") {
+		if strings.ContainsAny(segment, "{}") && !wildcardRe.MatchString(segment) ||
+			wildcardRe.MatchString(segment) && wildcardRe.FindString(segment) != segment {
+			return false
+		}
+	}
+	return true
+}
+
+// routeKey is the same for paths that only differ in wildcard names
+func routeKey(path string) string {
+	return wildcardRe.ReplaceAllStringFunc(path, func(wildcard string) string {
+		if wildcard == "{$}" {
+			return wildcard
+		}
+		if strings.HasSuffix(wildcard, "...}") {
+			return "{...}"
+		}
+		return "{}"
+	})
+}
+
+// wildcardNames maps the wildcard names in path to the ones in the registered
+// path of the same route, names that are the same are left out
+func wildcardNames(registered, path string) map[string]string {
+	names := map[string]string{}
+	want := wildcardRe.FindAllString(registered, -1)
+	for i, name := range wildcardRe.FindAllString(path, -1) {
+		from, to := strings.Trim(name, "{}."), strings.Trim(want[i], "{}.")
+		if from != to {
+			names[from] = to
+		}
+	}
+	return names
+}
+
+// withOperations adds operations that don't have a handler block on the same
+// method and path, operations are moved to the first path registered for their
+// route so the ServeMux doesn't see two patterns that only differ in names
+func withOperations(handlers, operations []*Handler) []*Handler {
+	paths := map[string]string{}
+	for _, h := range handlers {
+		paths[routeKey(h.Path)] = h.Path
+	}
+	merged := slices.Clone(handlers)
+	for _, op := range operations {
+		key := routeKey(op.Path)
+		overridden := slices.ContainsFunc(handlers, func(h *Handler) bool {
+			return routeKey(h.Path) == key && (h.Method == op.Method || h.Method == "*")
+		})
+		if overridden {
+			continue
+		}
+		if path, ok := paths[key]; ok {
+			op.operation.wildcards = wildcardNames(path, op.Path)
+			op.Path = path
+		} else {
+			paths[key] = op.Path
+		}
+		merged = append(merged, op)
+	}
+	return merged
+}
+
+func newOperation(spec *openapi.Spec, op openapi.Operation) *operation {
+	o := &operation{
+		spec:     spec,
+		params:   spec.Parameters(op),
+		response: Response{Status: http.StatusOK},
+	}
+	if spec.Swagger {
+		for _, param := range o.params {
+			if openapi.Str(param["in"]) == "body" {
+				o.body = map[string]any{
+					"required": param["required"],
+					"content":  map[string]any{"application/json": map[string]any{"schema": param["schema"]}},
+				}
+			}
+		}
+	} else {
+		o.body = openapi.Obj(spec.Resolve(op.Op["requestBody"]))
+	}
+
+	responses := openapi.Obj(op.Op["responses"])
+	code := documentedStatus(responses)
+	if status, err := strconv.Atoi(strings.ReplaceAll(strings.ToUpper(code), "X", "0")); err == nil {
+		o.response.Status = status
+	}
+	res := openapi.Obj(spec.Resolve(responses[code]))
+	var contentType string
+	var example any
+	if spec.Swagger {
+		contentType = "application/json"
+		if ex, ok := openapi.Obj(res["examples"])[contentType]; ok {
+			example = ex
+		} else {
+			example = spec.Example(res["schema"])
+		}
+	} else {
+		content := openapi.Obj(res["content"])
+		contentType = preferJSON(openapi.SortedKeys(content))
+		if contentType != "" {
+			example = spec.MediaExample(openapi.Obj(content[contentType]))
+		}
+	}
+	if example == nil || o.response.Status == http.StatusNoContent || o.response.Status == http.StatusNotModified {
+		return o
+	}
+	o.response.Headers = map[string]string{"Content-Type": contentType}
+	if str, ok := example.(string); ok && !strings.Contains(contentType, "json") {
+		o.response.Body = []byte(str)
+	} else if b, err := json.Marshal(example); err == nil {
+		o.response.Body = b
+	}
+	return o
+}
+
+// documentedStatus picks the first success response, then default, then
+// whatever is documented first
+func documentedStatus(responses map[string]any) string {
+	codes := openapi.SortedKeys(responses)
+	for _, code := range codes {
+		if strings.HasPrefix(code, "2") {
+			return code
+		}
+	}
+	if _, ok := responses["default"]; ok {
+		return "default"
+	}
+	if len(codes) > 0 {
+		return codes[0]
+	}
+	return ""
+}
+
+func preferJSON(contentTypes []string) string {
+	for _, ct := range contentTypes {
+		if strings.Contains(ct, "json") {
+			return ct
+		}
+	}
+	if len(contentTypes) > 0 {
+		return contentTypes[0]
+	}
+	return ""
+}
+
+// validate checks the request's parameters and body against the spec
+func (o *operation) validate(r *http.Request) []string {
+	errs := []string{}
+	for _, key := range openapi.SortedKeys(o.params) {
+		param := o.params[key]
+		name, in := openapi.Str(param["name"]), openapi.Str(param["in"])
+		var values []string
+		switch in {
+		case "path":
+			wildcard := name
+			if renamed, ok := o.wildcards[name]; ok {
+				wildcard = renamed
+			}
+			if v := r.PathValue(wildcard); v != "" {
+				values = []string{v}
+			}
+		case "query":
+			values = r.URL.Query()[name]
+		case "header":
+			values = r.Header.Values(name)
+		case "cookie":
+			if c, err := r.Cookie(name); err == nil {
+				values = []string{c.Value}
+			}
+		default:
+			// swagger body parameters are checked with the body
+			continue
+		}
+		if len(values) == 0 {
+			if required, _ := param["required"].(bool); required {
+				errs = append(errs, fmt.Sprintf("%s.%s: is required", in, name))
+			}
+			continue
+		}
+		schema := o.spec.ParamSchema(param)
+		errs = append(errs, o.spec.Validate(schema, o.spec.ParseParam(schema, values), in+"."+name)...)
+	}
+	return append(errs, o.validateBody(r)...)
+}
+
+func (o *operation) validateBody(r *http.Request) []string {
+	if o.body == nil {
+		return nil
+	}
+	body, err := peekBody(r)
+	if err != nil {
+		return []string{"body: " + err.Error()}
+	}
+	if len(body) == 0 {
+		if required, _ := o.body["required"].(bool); required {
+			return []string{"body: is required"}
+		}
+		return nil
+	}
+	content := openapi.Obj(o.body["content"])
+	if len(content) == 0 {
+		return nil
+	}
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	media, ok := content[contentType]
+	if !ok {
+		media, ok = content["*/*"]
+	}
+	if !ok {
+		if contentType != "" {
+			return []string{fmt.Sprintf("body: content type %s is not one of %s", contentType,
+				strings.Join(openapi.SortedKeys(content), ", "))}
+		}
+		// without a content type assume the documented one
+		contentType = preferJSON(openapi.SortedKeys(content))
+		media = content[contentType]
+	}
+	if !strings.Contains(contentType, "json") {
+		return nil
+	}
+	var decoded any
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return []string{"body: invalid json: " + err.Error()}
+	}
+	return o.spec.Validate(openapi.Obj(media)["schema"], decoded, "body")
+}
+
+// operationFn responds with 400 and what didn't match when the request isn't
+// valid, otherwise with the documented response
+func (s *Server) operationFn(o *operation) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if errs := o.validate(r); len(errs) > 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]any{
+				"error":   "request does not match the openapi spec",
+				"details": errs,
+			})
+			return
+		}
+		s.WriteResponseWithDefault(w, r, o.response)
+	}
+}
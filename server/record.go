@@ -0,0 +1,231 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/taybart/log"
+	"github.com/taybart/rest/history"
+)
+
+// Recording is an exchange with an upstream saved by proxy_record
+type Recording struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+	// sorted and encoded so it can be compared
+	Query          string      `json:"query,omitempty"`
+	RequestHeaders http.Header `json:"request_headers,omitempty"`
+	RequestBody    string      `json:"request_body,omitempty"`
+	BodyHash       string      `json:"body_hash"`
+
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+	// base64 when the body isn't utf-8
+	BodyEncoding string `json:"body_encoding,omitempty"`
+}
+
+// Key is what replay matches on
+func (rec *Recording) Key() string {
+	return rec.Method + " " + rec.Path + "?" + rec.Query + " " + rec.BodyHash
+}
+
+// ResponseBody decodes the recorded response body
+func (rec *Recording) ResponseBody() ([]byte, error) {
+	if rec.BodyEncoding == "base64" {
+		return base64.StdEncoding.DecodeString(rec.Body)
+	}
+	return []byte(rec.Body), nil
+}
+
+// newRecording fills in the request side of a recording
+func newRecording(r *http.Request, body []byte) *Recording {
+	return &Recording{
+		Time:           time.Now(),
+		Method:         r.Method,
+		Path:           r.URL.Path,
+		Query:          r.URL.Query().Encode(),
+		RequestHeaders: history.Redact(r.Header),
+		RequestBody:    string(body),
+		BodyHash:       hashBody(body),
+	}
+}
+
+func hashBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+var recordingNameRe = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+// filename is stable for a key so recording the same exchange again
+// overwrites it
+func (rec *Recording) filename() string {
+	sum := sha256.Sum256([]byte(rec.Key()))
+	path := strings.Trim(recordingNameRe.ReplaceAllString(rec.Path, "_"), "_")
+	if len(path) > 64 {
+		path = path[:64]
+	}
+	return fmt.Sprintf("%s_%s_%s.json", rec.Method, path, hex.EncodeToString(sum[:])[:12])
+}
+
+// Save writes the recording into dir, only readable by the user since bodies
+// can hold credentials
+func (rec *Recording) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, rec.filename()), b, 0o600)
+}
+
+// LoadRecordings reads every recording in dir, oldest first
+func LoadRecordings(dir string) ([]*Recording, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	recordings := []*Recording{}
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		var rec Recording
+		if err := json.Unmarshal(b, &rec); err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		recordings = append(recordings, &rec)
+	}
+	slices.SortStableFunc(recordings, func(a, b *Recording) int {
+		return a.Time.Compare(b.Time)
+	})
+	return recordings, nil
+}
+
+type recordingKey struct{}
+
+// NewProxy forwards to target, exchanges are saved into the proxy_record
+// directory when it is set
+func (s *Server) NewProxy(target string) http.HandlerFunc {
+	proxy := httputil.NewSingleHostReverseProxy(s.MustParseURL(target))
+	dir := s.Config.ProxyRecord
+	if dir == "" {
+		return proxy.ServeHTTP
+	}
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+		// let the transport handle compression so recorded bodies are readable
+		r.Header.Del("Accept-Encoding")
+	}
+	var mu sync.Mutex
+	proxy.ModifyResponse = func(res *http.Response) error {
+		rec, ok := res.Request.Context().Value(recordingKey{}).(*Recording)
+		if !ok {
+			return nil
+		}
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return err
+		}
+		res.Body = io.NopCloser(bytes.NewReader(body))
+
+		rec.Status = res.StatusCode
+		rec.Headers = history.Redact(res.Header)
+		if utf8.Valid(body) {
+			rec.Body = string(body)
+		} else {
+			rec.Body = base64.StdEncoding.EncodeToString(body)
+			rec.BodyEncoding = "base64"
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if err := rec.Save(dir); err != nil {
+			log.Errorf("saving recording: %v\n", err)
+		}
+		return nil
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := peekBody(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rec := newRecording(r, body)
+		proxy.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), recordingKey{}, rec)))
+	}
+}
+
+// HandleReplay serves recorded responses instead of proxying, requests are
+// matched on method, path, query and a hash of the body
+func (s *Server) HandleReplay() http.HandlerFunc {
+	recordings, err := LoadRecordings(s.Config.ProxyRecord)
+	if err != nil {
+		log.Errorf("loading recordings: %v\n", err)
+	}
+	byKey := map[string]*Recording{}
+	for _, rec := range recordings {
+		// the latest recording wins
+		byKey[rec.Key()] = rec
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := peekBody(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		key := newRecording(r, body).Key()
+		rec, ok := byKey[key]
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "no recording for " + key})
+			return
+		}
+		res, err := rec.ResponseBody()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for k, v := range rec.Headers {
+			if strings.EqualFold(k, "Content-Length") {
+				continue
+			}
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.Status)
+		w.Write(res)
+	}
+}
+
+// proxyHandler is the handler for a proxy target, recorded responses are
+// used instead when replaying
+func (s *Server) proxyHandler(target string) http.HandlerFunc {
+	if s.Config.Replay {
+		return s.HandleReplay()
+	}
+	return s.NewProxy(target)
+}
@@ -8,6 +8,7 @@ import (
 	"net/http/httputil"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -17,60 +18,151 @@ import (
 
 func (s *Server) Routes(server *http.Server) {
 	s.Router.HandleFunc("/__quit__", s.HandleQuit(server))
+	if s.journal == nil {
+		s.journal = NewJournal(s.Config.JournalSize)
+	}
+	s.Router.HandleFunc("/__requests__", s.HandleRequests())
+	s.Router.HandleFunc("/__verify__", s.HandleVerify())
+	if s.kv == nil {
+		s.kv, _ = NewKV("")
+	}
+	if s.scenarios == nil {
+		s.scenarios = newScenarios(s.Config.Scenarios)
+	}
+	if s.sockets == nil {
+		s.sockets = newSockets()
+	}
+	s.Router.HandleFunc("/__scenarios__", s.HandleScenarios())
+	s.Router.HandleFunc("/__scenarios__/reset", s.HandleScenarios())
 
 	if s.Config.Dir != "" {
-		s.Router.HandleFunc("/", gzipHandler(s.HandleDir()))
+		s.Router.HandleFunc("/", s.chaos(nil, gzipHandler(s.HandleDir())))
 		return
 	}
-	if s.Config.Proxy != "" {
-		proxy := httputil.NewSingleHostReverseProxy(s.MustParseURL(s.Config.Proxy))
-		s.Router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-			proxy.ServeHTTP(w, r)
-		})
+	// replaying doesn't need the upstream to be set
+	if s.Config.Proxy != "" || (s.Config.Replay && len(s.Config.Handlers) == 0) {
+		s.Router.HandleFunc("/", s.chaos(nil, s.proxyHandler(s.Config.Proxy)))
 		return
 	}
 	if !s.registerHandlerFns() {
-		s.Router.HandleFunc("/__ws__", s.HandleWSEcho("*"))
-		s.Router.HandleFunc("/__echo__", log.Middleware(gzipHandler(s.HandleEcho())))
-		s.Router.HandleFunc("/", log.Middleware(gzipHandler(s.HandleRoot())))
+		s.Router.HandleFunc("/__ws__", s.chaosFn(nil, s.HandleWSEcho("*"), false))
+		s.Router.HandleFunc("/__echo__", s.chaos(nil, log.Middleware(gzipHandler(s.HandleEcho()))))
+		s.Router.HandleFunc("/", s.chaos(nil, log.Middleware(gzipHandler(s.HandleRoot()))))
 	}
 }
 
 func (s *Server) registerHandlerFns() bool {
-	if len(s.Config.Handlers) > 0 {
-		rootRegistered := false
-		for _, handler := range s.Config.Handlers {
-			if handler.Path == "/" {
-				rootRegistered = true
-			}
-			log.Infof("registering handler %s%s%s\n", log.Blue, handler.Path, log.Reset)
-			if handler.WS {
-				s.Router.HandleFunc(handler.Path, s.HandleWSEcho(handler.Method))
-			} else {
-				s.Router.HandleFunc(handler.Path, log.Middleware(s.CustomHandlerFn(handler)))
+	handlers := s.Config.Handlers
+	if s.Config.OpenAPI != "" {
+		operations, err := s.openAPIHandlers()
+		if err != nil {
+			s.startErr = fmt.Errorf("loading openapi spec %s: %w", s.Config.OpenAPI, err)
+			log.Error(s.startErr)
+		}
+		handlers = withOperations(handlers, operations)
+	}
+	if len(handlers) > 0 {
+		// handlers on the same path share a route and are picked by match,
+		// paths that only differ in wildcard names are the same route too since
+		// the ServeMux won't register both
+		keys := []string{}
+		routes := map[string][]*Handler{}
+		for _, handler := range handlers {
+			key := routeKey(handler.Path)
+			if _, ok := routes[key]; !ok {
+				keys = append(keys, key)
 			}
+			routes[key] = append(routes[key], handler)
+		}
+		for _, key := range keys {
+			path := routes[key][0].Path
+			log.Infof("registering handler %s%s%s\n", log.Blue, path, log.Reset)
+			s.Router.HandleFunc(path, s.RouteFn(routes[key]))
 		}
-		if !rootRegistered {
+		if _, ok := routes["/"]; !ok {
 			// catch all with s.Config.Response as default if specified
-			s.Router.HandleFunc("/", log.Middleware(func(w http.ResponseWriter, r *http.Request) {
-				s.WriteResponseWithDefault(w, Response{Status: http.StatusNotFound})
-			}))
+			s.Router.HandleFunc("/", s.chaos(nil, log.Middleware(func(w http.ResponseWriter, r *http.Request) {
+				s.WriteResponseWithDefault(w, r, Response{Status: http.StatusNotFound})
+			})))
 		}
 		return true
 	}
 	return false
 }
+
+// RouteFn serves the first handler whose method and match block fit the
+// request, see sortHandlers for the order they are tried in. The route is
+// registered with the first handler's path, handlers that name its wildcards
+// differently get them under their own names
+func (s *Server) RouteFn(handlers []*Handler) http.HandlerFunc {
+	sorted := sortHandlers(handlers)
+	fns := make([]http.HandlerFunc, len(sorted))
+	renames := make([]map[string]string, len(sorted))
+	needsBody := false
+	names := make([]string, len(sorted))
+	for i, handler := range sorted {
+		names[i] = handler.Method + " " + handler.Path
+		if len(sorted) > 1 {
+			// tell apart handlers on the same path by file order
+			names[i] += fmt.Sprintf("#%d", slices.Index(handlers, handler))
+		}
+		switch {
+		case handler.WS && handler.Fn != "":
+			fns[i] = s.chaosFn(handler.Chaos, s.HandleWSScript(handler), false)
+		case handler.WS:
+			fns[i] = s.chaosFn(handler.Chaos, s.HandleWSEcho(handler.Method), false)
+		case handler.SSE != nil:
+			fns[i] = s.chaosFn(handler.Chaos, s.HandleSSE(handler), true)
+		case handler.operation != nil:
+			fns[i] = s.chaos(nil, log.Middleware(s.operationFn(handler.operation)))
+		default:
+			fns[i] = s.chaos(handler.Chaos, log.Middleware(s.CustomHandlerFn(handler)))
+		}
+		renames[i] = wildcardNames(handlers[0].Path, handler.Path)
+		needsBody = needsBody || handler.Match.needsBody()
+	}
+	notFound := s.chaos(nil, log.Middleware(func(w http.ResponseWriter, r *http.Request) {
+		s.WriteResponseWithDefault(w, r, Response{Status: http.StatusNotFound})
+	}))
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body []byte
+		if needsBody {
+			var err error
+			if body, err = peekBody(r); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		for i, handler := range sorted {
+			req := r
+			if len(renames[i]) > 0 {
+				req = r.Clone(r.Context())
+				for name, registered := range renames[i] {
+					req.SetPathValue(name, r.PathValue(registered))
+				}
+			}
+			if handler.matches(req, body) && s.scenarios.enter(handler) {
+				setJournalHandler(req, names[i])
+				fns[i](w, req)
+				return
+			}
+		}
+		notFound(w, r)
+	}
+}
+
 func (s *Server) CustomHandlerFn(handler *Handler) http.HandlerFunc {
 
-	var proxy *httputil.ReverseProxy
+	var proxy http.HandlerFunc
 	if handler.Proxy != "" {
-		proxy = httputil.NewSingleHostReverseProxy(s.MustParseURL(handler.Proxy))
+		proxy = s.proxyHandler(handler.Proxy)
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		if handler.Method == r.Method || handler.Method == "*" {
 			if handler.Proxy != "" {
-				proxy.ServeHTTP(w, r)
+				proxy(w, r)
 				return
 			}
 			if handler.Fn != "" {
@@ -88,11 +180,11 @@ func (s *Server) CustomHandlerFn(handler *Handler) http.HandlerFunc {
 				return
 			}
 			if handler.Response != nil {
-				s.WriteResponseWithDefault(w, *handler.Response)
+				s.WriteResponseWithDefault(w, r, *handler.Response)
 				return
 			}
 		}
-		s.WriteResponseWithDefault(w, Response{Status: http.StatusNotFound})
+		s.WriteResponseWithDefault(w, r, Response{Status: http.StatusNotFound})
 	}
 }
 
@@ -159,7 +251,7 @@ func (s *Server) HandleRoot() http.HandlerFunc {
 			}
 			fmt.Printf("%s%s%s\n", log.Yellow, string(dump), log.Rtd)
 		}
-		s.WriteConfigResponse(w)
+		s.WriteConfigResponse(w, r)
 	}
 }
 
@@ -171,13 +263,7 @@ func (s *Server) HandleWSEcho(method string) http.HandlerFunc {
 				r.Method,
 				r.URL.Path,
 			)
-			upgrader := websocket.Upgrader{
-				ReadBufferSize:  1024,
-				WriteBufferSize: 1024,
-			}
-			if s.Config.Cors {
-				upgrader.CheckOrigin = func(r *http.Request) bool { return true }
-			}
+			upgrader := s.upgrader()
 			conn, err := upgrader.Upgrade(w, r, nil)
 			if err != nil {
 				log.Error(err)
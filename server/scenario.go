@@ -0,0 +1,138 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Scenario is a named state machine, handlers can require one of its states
+// to respond and move it to a new state once they have
+type Scenario struct {
+	Name    string `json:"name" hcl:"name,label"`
+	Initial string `json:"initial" hcl:"initial"`
+}
+
+// scenarios holds the current state of every scenario
+type scenarios struct {
+	mu      sync.Mutex
+	initial map[string]string
+	states  map[string]string
+}
+
+func newScenarios(defined []*Scenario) *scenarios {
+	sc := &scenarios{initial: map[string]string{}, states: map[string]string{}}
+	for _, scenario := range defined {
+		sc.initial[scenario.Name] = scenario.Initial
+		sc.states[scenario.Name] = scenario.Initial
+	}
+	return sc
+}
+
+func (sc *scenarios) state(name string) (string, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	state, ok := sc.states[name]
+	return state, ok
+}
+
+func (sc *scenarios) set(name, state string) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if _, ok := sc.states[name]; !ok {
+		return fmt.Errorf("scenario %q is not defined", name)
+	}
+	sc.states[name] = state
+	return nil
+}
+
+// reset puts the named scenarios, or all of them, back in their initial state
+func (sc *scenarios) reset(names ...string) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if len(names) == 0 {
+		for name, initial := range sc.initial {
+			sc.states[name] = initial
+		}
+		return nil
+	}
+	for _, name := range names {
+		initial, ok := sc.initial[name]
+		if !ok {
+			return fmt.Errorf("scenario %q is not defined", name)
+		}
+		sc.states[name] = initial
+	}
+	return nil
+}
+
+func (sc *scenarios) all() map[string]string {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	states := make(map[string]string, len(sc.states))
+	for name, state := range sc.states {
+		states[name] = state
+	}
+	return states
+}
+
+// enter checks a handler's required_state and moves its scenario to
+// new_state in one step, so concurrent requests can't both get through
+func (sc *scenarios) enter(h *Handler) bool {
+	if h.Scenario == "" || (h.RequiredState == "" && h.NewState == "") {
+		return true
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	state, ok := sc.states[h.Scenario]
+	if !ok || h.RequiredState != "" && state != h.RequiredState {
+		return false
+	}
+	if h.NewState != "" {
+		sc.states[h.Scenario] = h.NewState
+	}
+	return true
+}
+
+// ValidateScenarios checks that handlers only use defined scenarios
+func ValidateScenarios(c Config) error {
+	defined := map[string]bool{}
+	for _, scenario := range c.Scenarios {
+		if defined[scenario.Name] {
+			return fmt.Errorf("scenario %q is defined twice", scenario.Name)
+		}
+		defined[scenario.Name] = true
+	}
+	for _, h := range c.Handlers {
+		if h.Scenario == "" {
+			if h.RequiredState != "" || h.NewState != "" {
+				return fmt.Errorf("handler %s %s: required_state and new_state need a scenario", h.Method, h.Path)
+			}
+			continue
+		}
+		if !defined[h.Scenario] {
+			return fmt.Errorf("handler %s %s: scenario %q is not defined", h.Method, h.Path, h.Scenario)
+		}
+	}
+	return nil
+}
+
+// HandleScenarios lists the current state of every scenario (GET) or resets
+// them to their initial state (POST /__scenarios__/reset), ?name= limits the
+// reset to one scenario
+func (s *Server) HandleScenarios() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/__scenarios__":
+		case r.Method == http.MethodPost && r.URL.Path == "/__scenarios__/reset":
+			if err := s.scenarios.reset(r.URL.Query()["name"]...); err != nil {
+				writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+				return
+			}
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, http.StatusOK, s.scenarios.all())
+	}
+}
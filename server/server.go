@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/hcl/v2"
@@ -32,13 +34,50 @@ type Handler struct {
 	Fn       string    `json:"fn" hcl:"fn,optional"`
 	Proxy    string    `json:"proxy" hcl:"proxy,optional"`
 	WS       bool      `json:"ws" hcl:"ws,optional"`
+	Every    string    `json:"every" hcl:"every,optional"`
+	Match    *Match    `json:"match" hcl:"match,block"`
+	Chaos    *Chaos    `json:"chaos" hcl:"chaos,block"`
+	SSE      *SSE      `json:"sse" hcl:"sse,block"`
 	Response *Response `hcl:"response,block"`
+	// only respond while the scenario is in required_state, then move it to
+	// new_state
+	Scenario      string `json:"scenario" hcl:"scenario,optional"`
+	RequiredState string `json:"required_state" hcl:"required_state,optional"`
+	NewState      string `json:"new_state" hcl:"new_state,optional"`
+	// set on handlers generated from the openapi spec
+	operation *operation
 }
 
 type Server struct {
-	Server *http.Server
-	Router *http.ServeMux
-	Config Config
+	Server    *http.Server
+	Router    *http.ServeMux
+	Config    Config
+	live      *liveHandler
+	journal   *Journal
+	kv        *KV
+	scenarios *scenarios
+	sockets   *sockets
+	// set when the server can't run as configured, Serve and Reload return it
+	startErr error
+}
+
+// liveHandler lets the routes be swapped without dropping the listener
+type liveHandler struct {
+	mu      sync.RWMutex
+	handler http.Handler
+}
+
+func (l *liveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l.mu.RLock()
+	handler := l.handler
+	l.mu.RUnlock()
+	handler.ServeHTTP(w, r)
+}
+
+func (l *liveHandler) swap(handler http.Handler) {
+	l.mu.Lock()
+	l.handler = handler
+	l.mu.Unlock()
 }
 
 type Config struct {
@@ -52,14 +91,43 @@ type Config struct {
 	TLS      string     `hcl:"tls,optional"`
 	Handlers []*Handler `hcl:"handler,block"`
 	SPA      bool       `hcl:"spa,optional"`
+	// directory proxied exchanges are saved to, with replay they are served
+	// from it instead of the upstream
+	ProxyRecord string `hcl:"proxy_record,optional"`
+	Replay      bool   `hcl:"replay,optional"`
+	// applies to every route, handlers with their own chaos block use that
+	Chaos     *Chaos      `hcl:"chaos,block"`
+	Scenarios []*Scenario `hcl:"scenario,block"`
+	// json file the lua kv store is saved to, in memory only when empty
+	KVFile string `hcl:"kv_file,optional"`
+	// number of requests kept for /__requests__ and /__verify__
+	JournalSize int `hcl:"journal_size,optional"`
+	// spec to mock, handler blocks override its operations
+	OpenAPI string `hcl:"openapi,optional"`
+	// with tls = "auto" the ca is loaded from tls_ca.{crt,key}, or generated
+	// and written there so clients can trust it
+	TLSCA string `hcl:"tls_ca,optional"`
+	// names the generated certificate is valid for besides localhost and the
+	// address
+	TLSHosts []string `hcl:"tls_hosts,optional"`
+	// require client certificates signed by this ca (pem)
+	TLSClientCA string `hcl:"tls_client_ca,optional"`
 }
 
 func New(c Config) Server {
 
 	s := Server{
-		Router: http.NewServeMux(),
-		Config: c,
+		Router:  http.NewServeMux(),
+		Config:  c,
+		live:    &liveHandler{},
+		journal: NewJournal(c.JournalSize),
+	}
+	kv, err := NewKV(c.KVFile)
+	if err != nil {
+		s.startErr = fmt.Errorf("loading kv from %s: %w", c.KVFile, err)
+		log.Error(s.startErr)
 	}
+	s.kv = kv
 
 	server := &http.Server{
 		Addr:         c.Addr,
@@ -68,22 +136,70 @@ func New(c Config) Server {
 	}
 	// weird thing: pass server in for shutdown route
 	s.Routes(server)
-	server.Handler = s.Router
-	if c.Cors {
-		server.Handler = cors.AllowAll().Handler(s.Router)
-	}
+	s.live.swap(s.handler())
+	server.Handler = s.live
 	s.Server = server
 	return s
 }
-func (s *Server) Serve() error {
 
+func (s *Server) handler() http.Handler {
+	handler := s.journal.record(s.Router)
+	if s.Config.Cors {
+		return cors.AllowAll().Handler(handler)
+	}
+	return handler
+}
+
+// Journal returns the requests the server has received
+func (s *Server) Journal() *Journal {
+	return s.journal
+}
+
+// Reload rebuilds the routes from a new config and swaps them in without
+// dropping the listener, the address, tls settings and kv_file can't be changed
+func (s *Server) Reload(c Config) error {
+	if c.Addr != s.Config.Addr || c.TLS != s.Config.TLS || c.TLSCA != s.Config.TLSCA ||
+		c.TLSClientCA != s.Config.TLSClientCA || !slices.Equal(c.TLSHosts, s.Config.TLSHosts) {
+		return errors.New("address and tls can't be changed without a restart")
+	}
+	if c.KVFile != s.Config.KVFile {
+		return errors.New("kv_file can't be changed without a restart")
+	}
+	// the journal, kv store and open websockets are kept across reloads,
+	// scenarios start over
+	next := Server{
+		Router:  http.NewServeMux(),
+		Config:  c,
+		live:    s.live,
+		Server:  s.Server,
+		journal: s.journal,
+		kv:      s.kv,
+		sockets: s.sockets,
+	}
+	next.Routes(s.Server)
+	if next.startErr != nil {
+		return next.startErr
+	}
+	s.live.swap(next.handler())
+	s.Router = next.Router
+	s.Config = c
+	s.scenarios = next.scenarios
+	return nil
+}
+func (s *Server) Serve() error {
+	if s.startErr != nil {
+		return s.startErr
+	}
 	if !s.Config.Quiet {
 		log.Infof("listening to %s...\n", s.Config.Addr)
 	}
 	if s.Config.TLS != "" {
-		crt := fmt.Sprintf("%s.crt", s.Config.TLS)
-		key := fmt.Sprintf("%s.key", s.Config.TLS)
-		if err := s.Server.ListenAndServeTLS(crt, key); err != nil {
+		config, err := s.TLSConfig()
+		if err != nil {
+			return err
+		}
+		s.Server.TLSConfig = config
+		if err := s.Server.ListenAndServeTLS("", ""); err != nil {
 			if !errors.Is(err, http.ErrServerClosed) {
 				return err
 			}
@@ -109,11 +225,13 @@ func (s *Server) MustParseURL(in string) *url.URL {
 	return u
 }
 
-func (s *Server) WriteConfigResponse(w http.ResponseWriter) error {
-
+// WriteConfigResponse writes the server's response block, templates in its
+// headers and body are rendered with r
+func (s *Server) WriteConfigResponse(w http.ResponseWriter, r *http.Request) error {
+	rr := newResponseRenderer(r)
 	if s.Config.Response != nil {
 		for k, v := range s.Config.Response.Headers {
-			w.Header().Add(k, v)
+			w.Header().Add(k, rr.render(v))
 		}
 	}
 
@@ -125,22 +243,25 @@ func (s *Server) WriteConfigResponse(w http.ResponseWriter) error {
 			status = res.Status
 		}
 		if len(res.Body) != 0 {
-			body = string(res.Body)
+			body = string(rr.renderBody(res.Body))
 		}
 	}
 	w.WriteHeader(status)
 	fmt.Fprint(w, body)
 	return nil
 }
-func (s *Server) WriteResponseWithDefault(w http.ResponseWriter, res Response) error {
 
+// WriteResponseWithDefault writes res, or the server's response block when
+// there is one, templates in headers and the body are rendered with r
+func (s *Server) WriteResponseWithDefault(w http.ResponseWriter, r *http.Request, res Response) error {
+	rr := newResponseRenderer(r)
 	if s.Config.Response != nil {
 		for k, v := range s.Config.Response.Headers {
-			w.Header().Add(k, v)
+			w.Header().Add(k, rr.render(v))
 		}
 	} else {
 		for k, v := range res.Headers {
-			w.Header().Add(k, v)
+			w.Header().Add(k, rr.render(v))
 		}
 	}
 
@@ -155,6 +276,6 @@ func (s *Server) WriteResponseWithDefault(w http.ResponseWriter, res Response) e
 		}
 	}
 	w.WriteHeader(status)
-	fmt.Fprint(w, string(body))
+	fmt.Fprint(w, string(rr.renderBody(body)))
 	return nil
 }
@@ -1,14 +1,22 @@
 package server_test
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/taybart/rest/server"
 )
 
@@ -110,3 +118,698 @@ func TestEcho(t *testing.T) {
 	}
 	checkResponse(t, req, Response{StatusCode: http.StatusOK, Body: `{"data": "hello"}`})
 }
+
+func TestReload(t *testing.T) {
+	s := server.New(server.Config{
+		Quiet:    true,
+		Handlers: []*server.Handler{{Method: "GET", Path: "/a", Response: &server.Response{Status: http.StatusTeapot}}},
+	})
+	ts := httptest.NewServer(s.Server.Handler)
+	defer ts.Close()
+
+	req, err := http.NewRequest("GET", ts.URL+"/a", nil)
+	if err != nil {
+		t.Errorf("error creating request: %s", err)
+	}
+	checkResponse(t, req, Response{StatusCode: http.StatusTeapot})
+
+	if err := s.Reload(server.Config{
+		Quiet:    true,
+		Handlers: []*server.Handler{{Method: "GET", Path: "/a", Response: &server.Response{Status: http.StatusAccepted}}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	checkResponse(t, req, Response{StatusCode: http.StatusAccepted})
+
+	if err := s.Reload(server.Config{Addr: "localhost:1", Quiet: true}); err == nil {
+		t.Fatal("expected address change to fail")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	ts := newServer(server.Config{
+		Quiet: true,
+		Handlers: []*server.Handler{
+			{Method: "GET", Path: "/users", Response: &server.Response{Status: http.StatusUnauthorized}},
+			{
+				Method:   "GET",
+				Path:     "/users",
+				Match:    &server.Match{Headers: map[string]string{"Authorization": "*"}},
+				Response: &server.Response{Status: http.StatusOK, Body: []byte("users")},
+			},
+			{
+				Method:   "GET",
+				Path:     "/users",
+				Match:    &server.Match{Query: map[string]string{"page": "/^[0-9]+$/"}, Headers: map[string]string{"Authorization": "*"}},
+				Response: &server.Response{Status: http.StatusOK, Body: []byte("page")},
+			},
+			{
+				Method:   "POST",
+				Path:     "/users",
+				Match:    &server.Match{BodyJSON: []byte(`{"user": {"role": "admin"}}`)},
+				Response: &server.Response{Status: http.StatusCreated, Body: []byte("admin")},
+			},
+			{
+				Method:   "POST",
+				Path:     "/users",
+				Match:    &server.Match{BodyRegex: "^name=", Priority: -1},
+				Response: &server.Response{Status: http.StatusCreated, Body: []byte("form")},
+			},
+		},
+	})
+	defer ts.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL+"/users", nil)
+	checkResponse(t, req, Response{StatusCode: http.StatusUnauthorized})
+
+	req, _ = http.NewRequest("GET", ts.URL+"/users", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	checkResponse(t, req, Response{StatusCode: http.StatusOK, Body: "users"})
+
+	req, _ = http.NewRequest("GET", ts.URL+"/users?page=2", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	checkResponse(t, req, Response{StatusCode: http.StatusOK, Body: "page"})
+
+	req, _ = http.NewRequest("POST", ts.URL+"/users", strings.NewReader(`{"user": {"name": "ada", "role": "admin"}}`))
+	checkResponse(t, req, Response{StatusCode: http.StatusCreated, Body: "admin"})
+
+	req, _ = http.NewRequest("POST", ts.URL+"/users", strings.NewReader(`name=ada`))
+	checkResponse(t, req, Response{StatusCode: http.StatusCreated, Body: "form"})
+
+	req, _ = http.NewRequest("POST", ts.URL+"/users", strings.NewReader(`{"user": {"role": "guest"}}`))
+	checkResponse(t, req, Response{StatusCode: http.StatusNotFound})
+}
+
+func TestJournal(t *testing.T) {
+	s := server.New(server.Config{
+		Quiet:       true,
+		JournalSize: 2,
+		Handlers: []*server.Handler{
+			{Method: "POST", Path: "/orders", Response: &server.Response{Status: http.StatusCreated}},
+		},
+	})
+	ts := httptest.NewServer(s.Server.Handler)
+	defer ts.Close()
+
+	for _, body := range []string{`{"sku": "a"}`, `{"sku": "b"}`, `{"sku": "c"}`} {
+		req, _ := http.NewRequest("POST", ts.URL+"/orders?source=test", strings.NewReader(body))
+		checkResponse(t, req, Response{StatusCode: http.StatusCreated})
+	}
+	req, _ := http.NewRequest("GET", ts.URL+"/missing", nil)
+	checkResponse(t, req, Response{StatusCode: http.StatusNotFound})
+
+	res, err := http.Get(ts.URL + "/__requests__?method=POST")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var entries []server.JournalEntry
+	if err := json.NewDecoder(res.Body).Decode(&entries); err != nil {
+		t.Fatal(err)
+	}
+	// the journal only holds the last 2 requests
+	if len(entries) != 1 || entries[0].Body != `{"sku": "c"}` || entries[0].Handler != "POST /orders" ||
+		entries[0].Status != http.StatusCreated {
+		t.Fatalf("unexpected journal %+v", entries)
+	}
+
+	req, _ = http.NewRequest("POST", ts.URL+"/__verify__",
+		strings.NewReader(`{"method": "POST", "path": "/orders", "query": {"source": "test"}, "body_json": {"sku": "c"}, "count": 1}`))
+	checkResponse(t, req, Response{StatusCode: http.StatusOK,
+		Body: `{"ok":true,"count":1,"expected":"1","requests":[` + mustJSON(t, entries[0]) + "]}\n"})
+
+	req, _ = http.NewRequest("POST", ts.URL+"/__verify__", strings.NewReader(`{"path": "/orders", "at_least": 2}`))
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusExpectationFailed {
+		t.Fatal("expected verify to fail got", res.StatusCode)
+	}
+
+	req, _ = http.NewRequest("DELETE", ts.URL+"/__requests__", nil)
+	checkResponse(t, req, Response{StatusCode: http.StatusNoContent})
+	if len(s.Journal().Entries()) != 0 {
+		t.Fatal("expected journal to be cleared")
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"path": "` + r.URL.Path + `", "sku": "` + r.URL.Query().Get("sku") + `", "body": ` + string(body) + `}`))
+	}))
+	dir := t.TempDir()
+
+	ts := newServer(server.Config{Proxy: upstream.URL, ProxyRecord: dir})
+	req, _ := http.NewRequest("POST", ts.URL+"/orders?sku=abc", strings.NewReader(`{"n": 1}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	recorded := Response{StatusCode: http.StatusCreated, Body: `{"path": "/orders", "sku": "abc", "body": {"n": 1}}`}
+	checkResponse(t, req, recorded)
+	ts.Close()
+	upstream.Close()
+
+	recordings, err := server.LoadRecordings(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recordings) != 1 || recordings[0].RequestBody != `{"n": 1}` || recordings[0].Status != http.StatusCreated {
+		t.Fatalf("unexpected recordings %+v", recordings)
+	}
+	if recordings[0].RequestHeaders.Get("Authorization") != "[redacted]" || recordings[0].Headers.Get("Set-Cookie") != "[redacted]" {
+		t.Fatalf("expected credentials to be redacted got %v %v", recordings[0].RequestHeaders, recordings[0].Headers)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if info, err := os.Stat(files[0]); err != nil || info.Mode().Perm()&0o077 != 0 {
+		t.Fatal("expected the recording to be private", err)
+	}
+
+	// the upstream is gone, responses come from the recordings
+	ts = newServer(server.Config{ProxyRecord: dir, Replay: true})
+	defer ts.Close()
+	req, _ = http.NewRequest("POST", ts.URL+"/orders?sku=abc", strings.NewReader(`{"n": 1}`))
+	checkResponse(t, req, recorded)
+	req, _ = http.NewRequest("POST", ts.URL+"/orders?sku=abc", strings.NewReader(`{"n": 2}`))
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Fatal("expected a different body not to be replayed got", res.StatusCode)
+	}
+}
+
+func TestChaos(t *testing.T) {
+	seed := uint64(42)
+	statuses := func() []int {
+		s := server.New(server.Config{
+			Quiet: true,
+			Chaos: &server.Chaos{ErrorRate: 0.5, Seed: &seed},
+			Handlers: []*server.Handler{
+				{Method: "GET", Path: "/flaky", Response: &server.Response{Status: http.StatusOK}},
+				{Method: "GET", Path: "/down", Chaos: &server.Chaos{DropConnectionRate: 1},
+					Response: &server.Response{Status: http.StatusOK}},
+				{Method: "GET", Path: "/slow", Chaos: &server.Chaos{Latency: "20ms..40ms", SlowBodyBPS: 100},
+					Response: &server.Response{Status: http.StatusOK, Body: []byte(`{"padding": "0123456789"}`)}},
+			},
+		})
+		ts := httptest.NewServer(s.Server.Handler)
+		defer ts.Close()
+
+		statuses := []int{}
+		for range 20 {
+			res, err := http.Get(ts.URL + "/flaky")
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			statuses = append(statuses, res.StatusCode)
+		}
+
+		if _, err := http.Get(ts.URL + "/down"); err == nil {
+			t.Fatal("expected the connection to be dropped")
+		}
+		entries := s.Journal().Find(server.JournalFilter{Path: "/down"})
+		if len(entries) == 0 || entries[0].Status != 0 {
+			t.Fatalf("expected the dropped request in the journal got %+v", entries)
+		}
+
+		start := time.Now()
+		req, _ := http.NewRequest("GET", ts.URL+"/slow", nil)
+		checkResponse(t, req, Response{StatusCode: http.StatusOK, Body: `{"padding": "0123456789"}`})
+		// 20ms of latency then 25 bytes in chunks of 10 every 100ms
+		if elapsed := time.Since(start); elapsed < 220*time.Millisecond {
+			t.Fatal("expected a slow response got", elapsed)
+		}
+		return statuses
+	}
+
+	first, second := statuses(), statuses()
+	if !slices.Equal(first, second) {
+		t.Fatalf("expected the same statuses with a seed got %v and %v", first, second)
+	}
+	if !slices.Contains(first, http.StatusOK) || !slices.Contains(first, http.StatusServiceUnavailable) {
+		t.Fatal("expected some requests to fail", first)
+	}
+}
+
+func TestOpenAPIMissingSpec(t *testing.T) {
+	s := server.New(server.Config{Quiet: true, Addr: "localhost:0", OpenAPI: filepath.Join(t.TempDir(), "missing.yaml")})
+	if err := s.Serve(); err == nil {
+		t.Fatal("expected serve to fail without the spec")
+	}
+}
+
+func TestWildcardNames(t *testing.T) {
+	ts := newServer(server.Config{
+		Handlers: []*server.Handler{
+			{Method: "GET", Path: "/users/{id}", Response: &server.Response{
+				Status: http.StatusOK, Body: []byte(`get {{path "id"}}`)}},
+			{Method: "DELETE", Path: "/users/{user_id}", Response: &server.Response{
+				Status: http.StatusOK, Body: []byte(`delete {{path "user_id"}}`)}},
+		},
+	})
+	defer ts.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL+"/users/1", nil)
+	checkResponse(t, req, Response{StatusCode: http.StatusOK, Body: "get 1"})
+	req, _ = http.NewRequest("DELETE", ts.URL+"/users/2", nil)
+	checkResponse(t, req, Response{StatusCode: http.StatusOK, Body: "delete 2"})
+}
+
+func TestChaosLatencyPastWriteTimeout(t *testing.T) {
+	s := server.New(server.Config{
+		Quiet: true,
+		Handlers: []*server.Handler{
+			{Method: "GET", Path: "/slow", Chaos: &server.Chaos{Latency: "200ms"},
+				Response: &server.Response{Status: http.StatusOK, Body: []byte("late")}},
+		},
+	})
+	ts := httptest.NewUnstartedServer(s.Server.Handler)
+	// stands in for the server's timeout so the test doesn't take 15s
+	ts.Config.WriteTimeout = 50 * time.Millisecond
+	ts.Start()
+	defer ts.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL+"/slow", nil)
+	checkResponse(t, req, Response{StatusCode: http.StatusOK, Body: "late"})
+}
+
+func TestScenario(t *testing.T) {
+	order := func(state string) *server.Response {
+		return &server.Response{Status: http.StatusOK, Body: []byte(`{"status":"` + state + `"}`)}
+	}
+	kvFile := filepath.Join(t.TempDir(), "kv.json")
+	s := server.New(server.Config{
+		Quiet:     true,
+		KVFile:    kvFile,
+		Scenarios: []*server.Scenario{{Name: "checkout", Initial: "pending"}},
+		Handlers: []*server.Handler{
+			{Method: "GET", Path: "/order/1", Scenario: "checkout", RequiredState: "pending", Response: order("pending")},
+			{Method: "GET", Path: "/order/1", Scenario: "checkout", RequiredState: "paid", Response: order("paid")},
+			{Method: "POST", Path: "/order/1/pay", Scenario: "checkout", RequiredState: "pending", NewState: "paid",
+				Fn: `
+					kv.set("payments", (kv.get("payments") or 0) + 1)
+					return { status = 201, body = s.state("checkout") }
+				`},
+		},
+	})
+	ts := httptest.NewServer(s.Server.Handler)
+	defer ts.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL+"/order/1", nil)
+	checkResponse(t, req, Response{StatusCode: http.StatusOK, Body: `{"status":"pending"}`})
+	// the state changes as soon as the handler is picked
+	req, _ = http.NewRequest("POST", ts.URL+"/order/1/pay", nil)
+	checkResponse(t, req, Response{StatusCode: http.StatusCreated, Body: "paid"})
+	req, _ = http.NewRequest("GET", ts.URL+"/order/1", nil)
+	checkResponse(t, req, Response{StatusCode: http.StatusOK, Body: `{"status":"paid"}`})
+	// already paid
+	req, _ = http.NewRequest("POST", ts.URL+"/order/1/pay", nil)
+	checkResponse(t, req, Response{StatusCode: http.StatusNotFound})
+
+	req, _ = http.NewRequest("GET", ts.URL+"/__scenarios__", nil)
+	checkResponse(t, req, Response{StatusCode: http.StatusOK, Body: `{"checkout":"paid"}` + "\n"})
+	req, _ = http.NewRequest("POST", ts.URL+"/__scenarios__/reset", nil)
+	checkResponse(t, req, Response{StatusCode: http.StatusOK, Body: `{"checkout":"pending"}` + "\n"})
+	req, _ = http.NewRequest("GET", ts.URL+"/order/1", nil)
+	checkResponse(t, req, Response{StatusCode: http.StatusOK, Body: `{"status":"pending"}`})
+
+	// only one of many concurrent payments gets through
+	var paid atomic.Int32
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Go(func() {
+			res, err := http.Post(ts.URL+"/order/1/pay", "", nil)
+			if err != nil {
+				return
+			}
+			res.Body.Close()
+			if res.StatusCode == http.StatusCreated {
+				paid.Add(1)
+			}
+		})
+	}
+	wg.Wait()
+	if paid.Load() != 1 {
+		t.Fatal("expected one concurrent payment to get through got", paid.Load())
+	}
+
+	kv, err := server.NewKV(kvFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payments, _ := kv.Get("payments"); payments != float64(2) {
+		t.Fatal("expected the kv store to be saved got", payments)
+	}
+}
+
+func TestKVFile(t *testing.T) {
+	dir := t.TempDir()
+	kvFile := filepath.Join(dir, "kv.json")
+	kv, err := server.NewKV(kvFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := kv.Set("n", float64(1)); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Fatal("expected only the kv file to be left got", files)
+	}
+
+	config := server.Config{Quiet: true, Addr: "localhost:0", KVFile: kvFile}
+	s := server.New(config)
+	config.KVFile = filepath.Join(dir, "other.json")
+	if err := s.Reload(config); err == nil {
+		t.Fatal("expected changing kv_file to need a restart")
+	}
+
+	if err := os.WriteFile(kvFile, []byte(`{"n": `), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	broken := server.New(server.Config{Quiet: true, Addr: "localhost:0", KVFile: kvFile})
+	if err := broken.Serve(); err == nil {
+		t.Fatal("expected a broken kv file to stop the server from starting")
+	}
+}
+
+func TestResponseTemplate(t *testing.T) {
+	ts := newServer(server.Config{
+		Handlers: []*server.Handler{
+			{Method: "GET", Path: "/users/{id}", Response: &server.Response{
+				Status:  http.StatusOK,
+				Headers: map[string]string{"X-Request-Id": `{{header "X-Request-Id"}}`},
+				Body:    []byte(`{"id":"{{path \"id\"}}","page":"{{query \"page\"}}","method":"{{method}}"}`),
+			}},
+			{Method: "POST", Path: "/users", Response: &server.Response{
+				Status: http.StatusCreated,
+				Body:   []byte(`{"id":"{{body \"user.id\"}}","name":"{{body \"user.name\"}}","tags":"{{body \"user.tags\"}}","n":"{{random_int 3 3}}","max":9007199254740993}`),
+			}},
+			{Method: "POST", Path: "/echo", Response: &server.Response{
+				Status: http.StatusOK,
+				Body:   []byte(`you sent {{body}} at {{now "2006"}}`),
+			}},
+		},
+	})
+	defer ts.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL+"/users/42?page=2", nil)
+	req.Header.Set("X-Request-Id", "abc")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if string(body) != `{"id":"42","method":"GET","page":"2"}` || res.Header.Get("X-Request-Id") != "abc" {
+		t.Fatalf("unexpected response %s %v", body, res.Header)
+	}
+
+	// values are escaped in json bodies and large numbers keep their precision
+	req, _ = http.NewRequest("POST", ts.URL+"/users", strings.NewReader(`{"user": {"id": 9007199254740993, "name": "a \"quoted\" name", "tags": ["x"]}}`))
+	checkResponse(t, req, Response{StatusCode: http.StatusCreated,
+		Body: `{"id":"9007199254740993","max":9007199254740993,"n":"3","name":"a \"quoted\" name","tags":"[\"x\"]"}`})
+
+	req, _ = http.NewRequest("POST", ts.URL+"/echo", strings.NewReader("hi"))
+	checkResponse(t, req, Response{StatusCode: http.StatusOK,
+		Body: "you sent hi at " + time.Now().Format("2006")})
+}
+
+func mustJSON(t *testing.T, v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestOpenAPI(t *testing.T) {
+	ts := newServer(server.Config{
+		Quiet:   true,
+		OpenAPI: "../doc/examples/server/openapi.yaml",
+		Handlers: []*server.Handler{
+			{Method: "DELETE", Path: "/v1/users/{user_id}", Response: &server.Response{Status: http.StatusForbidden}},
+		},
+	})
+	defer ts.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL+"/v1/users?limit=10", nil)
+	checkResponse(t, req, Response{StatusCode: http.StatusOK, Body: `[{"email":"user@example.com","id":0,"name":"Ada"}]`})
+	// moved to the handler's path, the spec's parameter name still works
+	req, _ = http.NewRequest("GET", ts.URL+"/v1/users/7", nil)
+	checkResponse(t, req, Response{StatusCode: http.StatusOK, Body: `{"email":"user@example.com","id":0,"name":"Ada"}`})
+	req, _ = http.NewRequest("DELETE", ts.URL+"/v1/users/7", nil)
+	checkResponse(t, req, Response{StatusCode: http.StatusForbidden})
+	req, _ = http.NewRequest("POST", ts.URL+"/v1/users", strings.NewReader(`{"name": "Ada"}`))
+	req.Header.Set("Content-Type", "application/json")
+	checkResponse(t, req, Response{StatusCode: http.StatusCreated, Body: `{"email":"ada@example.com","id":1,"name":"Ada"}`})
+
+	invalid := func(req *http.Request, details ...string) {
+		t.Helper()
+		checkResponse(t, req, Response{StatusCode: http.StatusBadRequest, Body: mustJSON(t, map[string]any{
+			"error":   "request does not match the openapi spec",
+			"details": details,
+		}) + "\n"})
+	}
+	req, _ = http.NewRequest("GET", ts.URL+"/v1/users?limit=1000", nil)
+	invalid(req, "query.limit: expected at most 100 got 1000")
+	req, _ = http.NewRequest("GET", ts.URL+"/v1/users/abc", nil)
+	invalid(req, "path.id: expected integer got string")
+	req, _ = http.NewRequest("POST", ts.URL+"/v1/users", strings.NewReader(`{"id": "1", "nickname": "a"}`))
+	invalid(req, "body.name: is required", "body.id: expected integer got string", "body.nickname: is not allowed")
+	req, _ = http.NewRequest("POST", ts.URL+"/v1/users", nil)
+	invalid(req, "body: is required")
+	req, _ = http.NewRequest("POST", ts.URL+"/v1/users", strings.NewReader(`name=Ada`))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	invalid(req, "body: content type application/x-www-form-urlencoded is not one of application/json")
+}
+
+func TestWSScript(t *testing.T) {
+	ts := newServer(server.Config{
+		Quiet: true,
+		Handlers: []*server.Handler{
+			{Method: "GET", Path: "/chat/{room}", WS: true, Fn: `
+				function on_connect()
+					ws.send("joined " .. s.path_value("room"))
+				end
+				function on_message(msg)
+					if msg == "bye" then
+						ws.close(4000, "bye")
+						return
+					end
+					ws.broadcast(msg)
+				end
+				function on_close(code, reason)
+					ws.broadcast("left " .. code .. " " .. reason)
+				end
+			`},
+			{Method: "GET", Path: "/ticks", WS: true, Every: "20ms", Fn: `
+				local count = 0
+				function on_tick()
+					count = count + 1
+					ws.send("tick " .. count)
+				end
+			`},
+		},
+	})
+	defer ts.Close()
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	dial := func(path string) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		return conn
+	}
+	expect := func(conn *websocket.Conn, expected string) {
+		t.Helper()
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(msg) != expected {
+			t.Fatalf("expected %q got %q", expected, msg)
+		}
+	}
+
+	alice, bob := dial("/chat/general"), dial("/chat/general")
+	defer alice.Close()
+	defer bob.Close()
+	expect(alice, "joined general")
+	expect(bob, "joined general")
+	if err := alice.WriteMessage(websocket.TextMessage, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	expect(alice, "hi")
+	expect(bob, "hi")
+
+	if err := bob.WriteMessage(websocket.TextMessage, []byte("bye")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := bob.ReadMessage(); !websocket.IsCloseError(err, 4000) {
+		t.Fatal("expected to be closed with 4000 got", err)
+	}
+	expect(alice, "left 4000 bye")
+
+	ticks := dial("/ticks")
+	defer ticks.Close()
+	expect(ticks, "tick 1")
+	expect(ticks, "tick 2")
+}
+
+func TestSSE(t *testing.T) {
+	// through the journal so flushing is checked with its writer
+	s := server.New(server.Config{
+		Quiet: true,
+		Handlers: []*server.Handler{
+			{Method: "GET", Path: "/events", SSE: &server.SSE{
+				Interval: "10ms",
+				Events: []server.SSEEvent{
+					{Event: "greeting", Data: []byte(`"hello\nworld"`)},
+					{ID: "second", Data: []byte(`{"n":2}`)},
+					{Data: []byte(`3`)},
+				},
+			}},
+			{Method: "GET", Path: "/feed", Fn: `
+				emit("resumed", last_event_id)
+				for i = 1, 2 do
+					emit("tick", { n = i }, tostring(i))
+					sleep("10ms")
+				end
+				emit("cr", "a\rb")
+				if pcall(emit, "x\ndata: injected", "y") then
+					emit("injected")
+				end
+			`, SSE: &server.SSE{KeepAlive: "5ms"}},
+		},
+	})
+	ts := httptest.NewServer(s.Server.Handler)
+	defer ts.Close()
+
+	stream := func(path, lastEventID string) (*http.Response, string) {
+		t.Helper()
+		req, _ := http.NewRequest("GET", ts.URL+path, nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res, string(body)
+	}
+
+	res, body := stream("/events", "")
+	if res.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatal("unexpected content type", res.Header.Get("Content-Type"))
+	}
+	expected := "id: 1\nevent: greeting\ndata: hello\ndata: world\n\n" +
+		"id: second\ndata: {\"n\":2}\n\n" +
+		"id: 3\ndata: 3\n\n"
+	if body != expected {
+		t.Fatalf("expected %q got %q", expected, body)
+	}
+	if _, body := stream("/events", "second"); body != "id: 3\ndata: 3\n\n" {
+		t.Fatalf("expected to resume after second got %q", body)
+	}
+	if res, _ := stream("/events", "3"); res.StatusCode != http.StatusNoContent {
+		t.Fatal("expected no content once every event was sent got", res.StatusCode)
+	}
+
+	_, body = stream("/feed", "7")
+	if !strings.Contains(body, ": keep-alive\n\n") {
+		t.Fatalf("expected keep-alive comments got %q", body)
+	}
+	body = strings.ReplaceAll(body, ": keep-alive\n\n", "")
+	expected = "event: resumed\ndata: 7\n\n" +
+		"id: 1\nevent: tick\ndata: {\"n\":1}\n\n" +
+		"id: 2\nevent: tick\ndata: {\"n\":2}\n\n" +
+		"event: cr\ndata: a\ndata: b\n\n"
+	if body != expected {
+		t.Fatalf("expected %q got %q", expected, body)
+	}
+}
+
+func TestTLSAuto(t *testing.T) {
+	dir := t.TempDir()
+	caName := filepath.Join(dir, "ca")
+	config := server.Config{Quiet: true, TLS: server.TLSAuto, TLSCA: caName, TLSHosts: []string{"api.local"}}
+	s := server.New(config)
+	tlsConfig, err := s.TLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewUnstartedServer(s.Server.Handler)
+	ts.TLS = tlsConfig
+	ts.StartTLS()
+	defer ts.Close()
+
+	ca, err := server.LoadCA(caName)
+	if err != nil {
+		t.Fatal("expected the ca to be written", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+	client := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs},
+		}}
+	}
+	res, err := client().Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	leaf := res.TLS.PeerCertificates[0]
+	if err := leaf.VerifyHostname("api.local"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the ca is reused so clients only have to trust it once
+	if again, _, err := server.LoadOrNewCA(caName); err != nil || !again.Cert.Equal(ca.Cert) {
+		t.Fatal("expected the same ca", err)
+	}
+
+	// mtls
+	config.TLSClientCA = caName + ".crt"
+	s = server.New(config)
+	if tlsConfig, err = s.TLSConfig(); err != nil {
+		t.Fatal(err)
+	}
+	mtls := httptest.NewUnstartedServer(s.Server.Handler)
+	mtls.TLS = tlsConfig
+	mtls.StartTLS()
+	defer mtls.Close()
+	if _, err := client().Get(mtls.URL); err == nil {
+		t.Fatal("expected requests without a client certificate to fail")
+	}
+	certPEM, keyPEM, err := ca.Issue([]string{"client"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = client(cert).Get(mtls.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatal("expected ok got", res.StatusCode)
+	}
+}
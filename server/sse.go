@@ -0,0 +1,303 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/taybart/log"
+	lua "github.com/yuin/gopher-lua"
+)
+
+const defaultSSEKeepAlive = 15 * time.Second
+
+// SSE streams server-sent events, either the listed events or the ones the
+// handler's fn emits
+type SSE struct {
+	Events    []SSEEvent     `json:"events"`
+	EventsHCL hcl.Expression `hcl:"events,optional"`
+	// time between events, or between runs of the fn
+	Interval string `json:"interval" hcl:"interval,optional"`
+	// start over after the last event (or when the fn returns)
+	Repeat bool `json:"repeat" hcl:"repeat,optional"`
+	// time between keep-alive comments, 15s by default
+	KeepAlive string `json:"keep_alive" hcl:"keep_alive,optional"`
+}
+
+// SSEEvent is one event in the stream, events without an id get their
+// position in the stream (starting at 1) so Last-Event-ID can resume them
+type SSEEvent struct {
+	ID    string          `json:"id"`
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+	// milliseconds the client should wait before reconnecting
+	Retry int `json:"retry"`
+}
+
+// ValidateSSE checks the sse block of a handler
+func (h *Handler) ValidateSSE() error {
+	c := h.SSE
+	if c == nil {
+		return nil
+	}
+	if h.WS {
+		return errors.New("sse can't be used with ws")
+	}
+	if len(c.Events) == 0 && h.Fn == "" {
+		return errors.New("sse needs events or an fn that calls emit")
+	}
+	interval, err := c.durations()
+	if err != nil {
+		return err
+	}
+	for i, e := range c.Events {
+		if err := e.validate(); err != nil {
+			return fmt.Errorf("sse event %d: %w", i, err)
+		}
+	}
+	if c.Repeat && interval <= 0 {
+		return errors.New("sse repeat needs an interval")
+	}
+	return nil
+}
+
+func (c *SSE) durations() (time.Duration, error) {
+	var interval time.Duration
+	for name, v := range map[string]string{"interval": c.Interval, "keep_alive": c.KeepAlive} {
+		if v == "" {
+			continue
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return 0, fmt.Errorf("sse %s %q is not a positive duration", name, v)
+		}
+		if name == "interval" {
+			interval = d
+		}
+	}
+	return interval, nil
+}
+
+func (c *SSE) keepAlive() time.Duration {
+	if d, err := time.ParseDuration(c.KeepAlive); err == nil && d > 0 {
+		return d
+	}
+	return defaultSSEKeepAlive
+}
+
+// resume is the position after Last-Event-ID, ids that are listed are looked
+// up and positions are used for the rest
+func (c *SSE) resume(last string) int {
+	if last == "" {
+		return 0
+	}
+	for i, e := range c.Events {
+		if e.ID == last {
+			return i + 1
+		}
+	}
+	if n, err := strconv.Atoi(last); err == nil && n > 0 {
+		return n
+	}
+	return 0
+}
+
+// sseStream writes events as they come, keep-alives are written from another
+// goroutine so writes are serialized
+type sseStream struct {
+	mu sync.Mutex
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func (st *sseStream) write(msg string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if _, err := fmt.Fprint(st.w, msg); err != nil {
+		return err
+	}
+	return st.rc.Flush()
+}
+
+// errSSEField is returned for ids and event names with line breaks, they
+// would end the field and let the rest be read as other fields
+var errSSEField = errors.New("sse id and event can't contain line breaks")
+
+func (e SSEEvent) validate() error {
+	if strings.ContainsAny(e.ID, "\r\n") || strings.ContainsAny(e.Event, "\r\n") {
+		return errSSEField
+	}
+	return nil
+}
+
+func (st *sseStream) send(e SSEEvent) error {
+	if err := e.validate(); err != nil {
+		return err
+	}
+	var b strings.Builder
+	if e.ID != "" {
+		fmt.Fprintf(&b, "id: %s\n", e.ID)
+	}
+	if e.Event != "" {
+		fmt.Fprintf(&b, "event: %s\n", e.Event)
+	}
+	if e.Retry > 0 {
+		fmt.Fprintf(&b, "retry: %d\n", e.Retry)
+	}
+	data := string(e.Data)
+	// strings are sent as is, anything else as json
+	var str string
+	if json.Unmarshal(e.Data, &str) == nil {
+		data = str
+	}
+	// clients split lines on any of \r\n, \r and \n
+	data = strings.ReplaceAll(strings.ReplaceAll(data, "\r\n", "\n"), "\r", "\n")
+	for line := range strings.SplitSeq(data, "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+	return st.write(b.String())
+}
+
+func (st *sseStream) comment(text string) error {
+	return st.write(": " + text + "\n\n")
+}
+
+// sleep waits for d unless the client goes away first
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// HandleSSE streams the handler's events, flushing each one. It isn't wrapped
+// in log.Middleware since that buffers the whole response
+func (s *Server) HandleSSE(handler *Handler) http.HandlerFunc {
+	c := handler.SSE
+	interval, _ := c.durations()
+	keepAlive := c.keepAlive()
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Infof("%s %s\n", r.Method, r.URL.Path)
+
+		start := c.resume(r.Header.Get("Last-Event-ID"))
+		if handler.Fn == "" && !c.Repeat && start >= len(c.Events) {
+			// tells EventSource there is nothing left so it stops reconnecting
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		rc := http.NewResponseController(w)
+		// streams outlive the server's write timeout
+		rc.SetWriteDeadline(time.Time{})
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		st := &sseStream{w: w, rc: rc}
+		if err := rc.Flush(); err != nil {
+			log.Errorf("sse stream can't be flushed: %v\n", err)
+			return
+		}
+
+		ctx, cancel := context.WithCancel(r.Context())
+		var keepingAlive sync.WaitGroup
+		keepingAlive.Go(func() {
+			ticker := time.NewTicker(keepAlive)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if err := st.comment("keep-alive"); err != nil {
+						cancel()
+						return
+					}
+				}
+			}
+		})
+		defer keepingAlive.Wait()
+		defer cancel()
+
+		if handler.Fn != "" {
+			s.runSSEScript(ctx, handler, r, st, interval)
+			return
+		}
+		for pos := start; c.Repeat || pos < len(c.Events); pos++ {
+			if pos > start && interval > 0 && !sleep(ctx, interval) {
+				return
+			}
+			e := c.Events[pos%len(c.Events)]
+			if e.ID == "" {
+				e.ID = strconv.Itoa(pos + 1)
+			}
+			if err := st.send(e); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// runSSEScript runs the handler's fn with emit(event, data, id) and
+// sleep(duration), with repeat it is run again every interval
+func (s *Server) runSSEScript(ctx context.Context, handler *Handler, r *http.Request, st *sseStream, interval time.Duration) {
+	l, err := s.luaState(r)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	defer l.Close()
+
+	l.SetGlobal("last_event_id", lua.LString(r.Header.Get("Last-Event-ID")))
+	l.SetGlobal("emit", l.NewFunction(func(l *lua.LState) int {
+		e := SSEEvent{Event: l.OptString(1, ""), ID: l.OptString(3, "")}
+		switch data := l.Get(2).(type) {
+		case lua.LString:
+			e.Data, _ = json.Marshal(string(data))
+		case *lua.LNilType:
+		default:
+			b, err := json.Marshal(fromLValue(data))
+			if err != nil {
+				l.RaiseError("emit: %v", err)
+			}
+			e.Data = b
+		}
+		if err := st.send(e); err != nil {
+			l.RaiseError("emit: %v", err)
+		}
+		return 0
+	}))
+	l.SetGlobal("sleep", l.NewFunction(func(l *lua.LState) int {
+		d, err := time.ParseDuration(l.CheckString(1))
+		if err != nil {
+			l.ArgError(1, err.Error())
+		}
+		if !sleep(ctx, d) {
+			l.RaiseError("client disconnected")
+		}
+		return 0
+	}))
+
+	for {
+		if err := execute(l, handler.Fn); err != nil {
+			// errors from the client going away aren't the script's fault
+			if ctx.Err() == nil {
+				log.Error(err)
+			}
+			return
+		}
+		if !handler.SSE.Repeat || !sleep(ctx, interval) {
+			return
+		}
+	}
+}
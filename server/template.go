@@ -0,0 +1,208 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/taybart/log"
+)
+
+// response templates are go templates, hcl leaves {{ }} alone so they are
+// only rendered once a request comes in
+const templateStart = "{{"
+
+// templateFuncs are replaced per request, these are only used for parsing
+var templateFuncs = template.FuncMap{
+	"method":     func() string { return "" },
+	"path":       func(string) string { return "" },
+	"query":      func(string) string { return "" },
+	"header":     func(string) string { return "" },
+	"body":       func(...string) string { return "" },
+	"now":        func(...string) string { return "" },
+	"unix_now":   func() int64 { return 0 },
+	"uuid":       func() string { return "" },
+	"random_int": func(int, int) (int, error) { return 0, nil },
+}
+
+var templates sync.Map
+
+func parseTemplate(text string) (*template.Template, error) {
+	if t, ok := templates.Load(text); ok {
+		return t.(*template.Template), nil
+	}
+	t, err := template.New("response").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	templates.Store(text, t)
+	return t, nil
+}
+
+// requestFuncs give templates access to the request being responded to
+func requestFuncs(r *http.Request, body []byte) template.FuncMap {
+	var decoded any
+	decodedOnce := sync.OnceFunc(func() { decodeJSON(body, &decoded) })
+	return template.FuncMap{
+		"method": func() string { return r.Method },
+		"path":   r.PathValue,
+		"query":  r.URL.Query().Get,
+		"header": r.Header.Get,
+		// body returns the raw body or the field at a dotted path (user.name,
+		// items.0.id) of a json body
+		"body": func(path ...string) string {
+			if len(path) == 0 {
+				return string(body)
+			}
+			decodedOnce()
+			return jsonField(decoded, path[0])
+		},
+		// now is RFC 3339 unless given a go time layout
+		"now": func(layout ...string) string {
+			if len(layout) > 0 {
+				return time.Now().Format(layout[0])
+			}
+			return time.Now().Format(time.RFC3339)
+		},
+		"unix_now": func() int64 { return time.Now().Unix() },
+		"uuid":     uuid.NewString,
+		"random_int": func(lo, hi int) (int, error) {
+			if hi < lo {
+				return 0, fmt.Errorf("max (%d) must be greater than min (%d)", hi, lo)
+			}
+			return lo + rand.IntN(hi-lo+1), nil
+		},
+	}
+}
+
+func jsonField(v any, path string) string {
+	for key := range strings.SplitSeq(path, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			v = node[key]
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return ""
+			}
+			v = node[i]
+		default:
+			return ""
+		}
+	}
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// decodeJSON keeps numbers as they were written so large ids don't lose
+// precision as float64
+func decodeJSON(b []byte, v *any) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("unexpected data after the json value")
+	}
+	return nil
+}
+
+// responseRenderer renders templates in a response for one request
+type responseRenderer struct {
+	r     *http.Request
+	funcs template.FuncMap
+}
+
+func newResponseRenderer(r *http.Request) *responseRenderer {
+	if r == nil {
+		return nil
+	}
+	return &responseRenderer{r: r}
+}
+
+// requestFuncs reads the body so it is only called for responses with templates
+func (rr *responseRenderer) requestFuncs() template.FuncMap {
+	if rr.funcs == nil {
+		body, err := peekBody(rr.r)
+		if err != nil {
+			log.Errorf("reading body for response template: %v\n", err)
+		}
+		rr.funcs = requestFuncs(rr.r, body)
+	}
+	return rr.funcs
+}
+
+// render executes text as a template, text without one or that fails to
+// render is returned as is
+func (rr *responseRenderer) render(text string) string {
+	if rr == nil || !strings.Contains(text, templateStart) {
+		return text
+	}
+	t, err := parseTemplate(text)
+	if err != nil {
+		log.Errorf("response template: %v\n", err)
+		return text
+	}
+	t, err = t.Clone()
+	if err != nil {
+		log.Errorf("response template: %v\n", err)
+		return text
+	}
+	var b strings.Builder
+	if err := t.Funcs(rr.requestFuncs()).Execute(&b, nil); err != nil {
+		log.Errorf("response template: %v\n", err)
+		return text
+	}
+	return b.String()
+}
+
+// renderBody renders each string in a json body on its own so values from the
+// request are escaped, other bodies are rendered as a whole
+func (rr *responseRenderer) renderBody(body []byte) []byte {
+	if rr == nil || !bytes.Contains(body, []byte(templateStart)) {
+		return body
+	}
+	var decoded any
+	if err := decodeJSON(body, &decoded); err != nil {
+		return []byte(rr.render(string(body)))
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(rr.renderJSON(decoded)); err != nil {
+		return body
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n"))
+}
+
+func (rr *responseRenderer) renderJSON(v any) any {
+	switch v := v.(type) {
+	case string:
+		return rr.render(v)
+	case []any:
+		for i := range v {
+			v[i] = rr.renderJSON(v[i])
+		}
+	case map[string]any:
+		for k, e := range v {
+			v[k] = rr.renderJSON(e)
+		}
+	}
+	return v
+}
@@ -0,0 +1,252 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/taybart/log"
+	restlua "github.com/taybart/rest/lua"
+	lua "github.com/yuin/gopher-lua"
+)
+
+const wsWriteTimeout = 5 * time.Second
+
+// ValidateWS checks the websocket options of a handler
+func (h *Handler) ValidateWS() error {
+	if h.Every == "" {
+		return nil
+	}
+	if !h.WS || h.Fn == "" {
+		return errors.New("every needs ws = true and an fn with on_tick")
+	}
+	if d, err := time.ParseDuration(h.Every); err != nil || d <= 0 {
+		return fmt.Errorf("every %q is not a positive duration", h.Every)
+	}
+	return nil
+}
+
+func (s *Server) upgrader() websocket.Upgrader {
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+	}
+	if s.Config.Cors {
+		upgrader.CheckOrigin = func(r *http.Request) bool { return true }
+	}
+	return upgrader
+}
+
+// sockets are the open connections of scripted websocket handlers by path, so
+// a connection can broadcast to the others on its path
+type sockets struct {
+	mu    sync.Mutex
+	paths map[string]map[*socket]struct{}
+}
+
+func newSockets() *sockets {
+	return &sockets{paths: map[string]map[*socket]struct{}{}}
+}
+
+func (ss *sockets) add(path string, sock *socket) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.paths[path] == nil {
+		ss.paths[path] = map[*socket]struct{}{}
+	}
+	ss.paths[path][sock] = struct{}{}
+}
+
+func (ss *sockets) remove(path string, sock *socket) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	delete(ss.paths[path], sock)
+}
+
+func (ss *sockets) broadcast(path string, messageType int, msg []byte) {
+	ss.mu.Lock()
+	conns := make([]*socket, 0, len(ss.paths[path]))
+	for sock := range ss.paths[path] {
+		conns = append(conns, sock)
+	}
+	ss.mu.Unlock()
+	for _, sock := range conns {
+		if err := sock.send(messageType, msg); err != nil {
+			log.Errorf("[ws] broadcast to %s: %v\n", sock.id, err)
+		}
+	}
+}
+
+// socket is one scripted connection, its lua state is only used by one
+// callback at a time and writes are serialized since broadcasts come from
+// other connections
+type socket struct {
+	id      string
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+	luaMu   sync.Mutex
+	l       *lua.LState
+	// set when the script closes the connection, clients don't have to send
+	// the reason back
+	closed      atomic.Bool
+	closeCode   int
+	closeReason string
+}
+
+func (sock *socket) send(messageType int, msg []byte) error {
+	sock.writeMu.Lock()
+	defer sock.writeMu.Unlock()
+	sock.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return sock.conn.WriteMessage(messageType, msg)
+}
+
+// close sends a close frame, the read loop ends when the client answers or
+// after a second
+func (sock *socket) close(code int, reason string) error {
+	if sock.closed.Swap(true) {
+		return nil
+	}
+	sock.closeCode, sock.closeReason = code, reason
+	msg := websocket.FormatCloseMessage(code, reason)
+	if err := sock.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsWriteTimeout)); err != nil {
+		return err
+	}
+	return sock.conn.SetReadDeadline(time.Now().Add(time.Second))
+}
+
+// call runs a callback the script defined, callbacks that aren't defined are
+// skipped
+func (sock *socket) call(name string, args ...lua.LValue) error {
+	sock.luaMu.Lock()
+	defer sock.luaMu.Unlock()
+	fn, ok := sock.l.GetGlobal(name).(*lua.LFunction)
+	if !ok {
+		return nil
+	}
+	if err := sock.l.CallByParam(lua.P{Fn: fn, NRet: 0, Protect: true}, args...); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+func (s *Server) wsTable(l *lua.LState, path string, sock *socket) *lua.LTable {
+	send := func(l *lua.LState) int {
+		if err := sock.send(websocket.TextMessage, []byte(l.CheckString(1))); err != nil {
+			l.RaiseError("%v", err)
+		}
+		return 0
+	}
+	broadcast := func(l *lua.LState) int {
+		s.sockets.broadcast(path, websocket.TextMessage, []byte(l.CheckString(1)))
+		return 0
+	}
+	closeFn := func(l *lua.LState) int {
+		code := l.OptInt(1, websocket.CloseNormalClosure)
+		if err := sock.close(code, l.OptString(2, "")); err != nil {
+			l.RaiseError("%v", err)
+		}
+		return 0
+	}
+	return restlua.MakeLTable(l, map[string]lua.LValue{
+		"id":        lua.LString(sock.id),
+		"send":      l.NewFunction(send),
+		"broadcast": l.NewFunction(broadcast),
+		"close":     l.NewFunction(closeFn),
+	})
+}
+
+// HandleWSScript runs the handler's fn for each connection, the script defines
+// on_connect(), on_message(msg), on_close(code, reason) and on_tick() which is
+// called every handler.Every
+func (s *Server) HandleWSScript(handler *Handler) http.HandlerFunc {
+	var every time.Duration
+	if handler.Every != "" {
+		every, _ = time.ParseDuration(handler.Every)
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Infof("%s %s\n", r.Method, r.URL.Path)
+
+		// set up before upgrading so errors can be a normal response
+		l, err := s.luaState(r)
+		if err != nil {
+			log.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer l.Close()
+
+		upgrader := s.upgrader()
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		sock := &socket{id: uuid.NewString(), conn: conn, l: l}
+		l.SetGlobal("ws", s.wsTable(l, handler.Path, sock))
+		if err := execute(l, handler.Fn); err != nil {
+			log.Error(err)
+			sock.close(websocket.CloseInternalServerErr, "handler error")
+			return
+		}
+		s.sockets.add(handler.Path, sock)
+
+		if err := sock.call("on_connect"); err != nil {
+			log.Error(err)
+			sock.close(websocket.CloseInternalServerErr, "handler error")
+		}
+
+		done := make(chan struct{})
+		var ticking sync.WaitGroup
+		if every > 0 {
+			ticking.Go(func() {
+				ticker := time.NewTicker(every)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-done:
+						return
+					case <-ticker.C:
+						if err := sock.call("on_tick"); err != nil {
+							log.Error(err)
+						}
+					}
+				}
+			})
+		}
+
+		code, reason := websocket.CloseAbnormalClosure, ""
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				var closeErr *websocket.CloseError
+				if errors.As(err, &closeErr) {
+					code, reason = closeErr.Code, closeErr.Text
+				} else if !sock.closed.Load() {
+					log.Error(err)
+				}
+				break
+			}
+			log.Infof("[ws] %s %s\n", sock.id, msg)
+			if err := sock.call("on_message", lua.LString(msg)); err != nil {
+				log.Error(err)
+			}
+		}
+		// the lua state is closed after this so the ticker has to be done
+		close(done)
+		ticking.Wait()
+		s.sockets.remove(handler.Path, sock)
+		if sock.closed.Load() {
+			code, reason = sock.closeCode, sock.closeReason
+		}
+		if err := sock.call("on_close", lua.LNumber(code), lua.LString(reason)); err != nil {
+			log.Error(err)
+		}
+	}
+}
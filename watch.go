@@ -0,0 +1,131 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/taybart/log"
+	"github.com/taybart/rest/server"
+)
+
+const (
+	watchInterval = 200 * time.Millisecond
+	// wait for writes to settle, editors tend to write files more than once
+	watchDebounce = 300 * time.Millisecond
+)
+
+type snapshot map[string]time.Time
+
+func takeSnapshot(files []string) snapshot {
+	snap := snapshot{}
+	for _, f := range files {
+		if info, err := os.Stat(f); err == nil {
+			snap[f] = info.ModTime()
+		} else {
+			snap[f] = time.Time{}
+		}
+	}
+	return snap
+}
+
+// changed returns the first file that is different between snapshots
+func (s snapshot) changed(files []string) (string, bool) {
+	next := takeSnapshot(files)
+	for f, mod := range next {
+		if !s[f].Equal(mod) {
+			return f, true
+		}
+	}
+	return "", false
+}
+
+func waitForChange(ctx context.Context, files []string) (string, error) {
+	snap := takeSnapshot(files)
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-ticker.C:
+		}
+		changed, ok := snap.changed(files)
+		if !ok {
+			continue
+		}
+		// debounce until the files stop changing
+		for {
+			snap = takeSnapshot(files)
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(watchDebounce):
+			}
+			if _, ok := snap.changed(files); !ok {
+				return changed, nil
+			}
+		}
+	}
+}
+
+func separator(changed string) {
+	fmt.Printf("\n%s──── %s %s ────%s\n\n",
+		log.Blue, time.Now().Format(time.TimeOnly), changed, log.Reset)
+}
+
+// Watch parses filename and calls run, then re-parses and re-runs every time
+// the file, its imports or anything it read() changes
+func Watch(ctx context.Context, filename string, run func(*Rest) error) error {
+	deps := []string{filename}
+	for {
+		if f, err := NewFile(filename); err != nil {
+			log.Error(err)
+		} else {
+			if err := run(f); err != nil {
+				log.Error(err)
+			}
+			deps = f.Parser.Dependencies()
+		}
+		log.Infof("watching %d file(s) for changes...\n", len(deps))
+		changed, err := waitForChange(ctx, deps)
+		if err != nil {
+			return nil
+		}
+		separator(changed)
+	}
+}
+
+// WatchServer runs the server block and hot reloads its handlers when the
+// file changes, the listener is kept open between reloads
+func WatchServer(ctx context.Context, filename string) error {
+	var s *server.Server
+	// the watcher and the server both send, only the first is read so each
+	// needs room to send without blocking forever
+	errs := make(chan error, 2)
+	go func() {
+		errs <- Watch(ctx, filename, func(f *Rest) error {
+			config, err := f.Parser.Server()
+			if err != nil {
+				return err
+			}
+			if config.Addr == "" {
+				return errors.New("missing required server block")
+			}
+			if s != nil {
+				if err := s.Reload(config); err != nil {
+					return err
+				}
+				log.Info("reloaded server handlers")
+				return nil
+			}
+			srv := server.New(config)
+			s = &srv
+			go func() { errs <- s.Serve() }()
+			return nil
+		})
+	}()
+	return <-errs
+}